@@ -1,54 +1,199 @@
 package main
 
 import (
+	"context"
+	"go-metrics-server/internal/agent/collector"
 	"go-metrics-server/internal/agent/config"
 	"go-metrics-server/internal/agent/metrics"
-	"go-metrics-server/internal/agent/sender"
+	"go-metrics-server/internal/agent/pull"
+	"go-metrics-server/internal/agent/statsd"
+	"go-metrics-server/internal/models"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 )
 
 func main() {
 	cfg := config.NewConfig()
-	metricsCollector := metrics.NewMetrics()
-	sender := sender.New(cfg.ServerAddr, cfg.Key)
+	upstreams, breakers, err := newUpstreams(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create sender: %v", err)
+	}
 
-	metricsChan := make(chan map[string]interface{})
-	done := make(chan struct{})
-	var wg sync.WaitGroup
+	registry, err := collector.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create collectors: %v", err)
+	}
+	// Состояние доставки на каждый сервер отправляется вместе с метриками агента
+	registry.Register(collector.NewFunc("delivery", func(context.Context) ([]models.Metrics, error) {
+		var result []models.Metrics
+		for addr, breaker := range breakers {
+			result = append(result, breaker.Metrics(map[string]string{ServerLabel: addr})...)
+		}
+		for _, u := range upstreams {
+			queued := float64(u.queue.Len())
+			result = append(result, models.Metrics{
+				ID: "OutboxBatches", MType: "gauge", Value: &queued, Labels: map[string]string{ServerLabel: u.name},
+			})
+		}
+		return result, nil
+	}))
+	log.Printf("Enabled collectors: %v", registry.Names())
+	metricsCollector := metrics.NewMetrics(registry)
+	metricsCollector.Aggregator, err = metrics.NewAggregator(cfg.GaugeAggregates, cfg.GaugeMode)
+	if err != nil {
+		log.Fatalf("Invalid gauge aggregation: %v", err)
+	}
 
-	for i := 0; i < cfg.RateLimit; i++ {
-		wg.Add(1)
+	deltas := metrics.NewDeltaTracker()
+	metricsCollector.Tracker = deltas
+
+	// Агент работает до сигнала остановки
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Метрики приложений по StatsD накапливаются за интервал отправки
+	var statsdListener *statsd.Listener
+	if cfg.StatsDAddr != "" {
+		buffer, err := statsd.NewBuffer(cfg.StatsDTimers)
+		if err != nil {
+			log.Fatalf("Invalid StatsD configuration: %v", err)
+		}
+		statsdListener, err = statsd.Listen(cfg.StatsDAddr, buffer)
+		if err != nil {
+			log.Fatalf("Failed to start StatsD listener: %v", err)
+		}
 		go func() {
-			defer wg.Done()
-			for metrics := range metricsChan {
-				if err := sender.SendMetricsBatch(metrics); err != nil {
-					log.Printf("Failed to send metrics batch: %v", err)
-				}
+			if err := statsdListener.Serve(); err != nil {
+				log.Printf("StatsD listener error: %v", err)
+			}
+		}()
+		log.Printf("Accepting StatsD metrics on udp://%s", statsdListener.Addr())
+	}
+
+	// report ставит снимок метрик в очереди всех направлений. Счетчики агента
+	// накопительные, серверу отправляются дельты; метрики StatsD уже собраны
+	// за интервал и добавляются к пакету как есть. Попадание пакета в очередь
+	// считается подтверждением доставки, после него сбрасывается и окно
+	// агрегации gauge
+	report := func() {
+		snapshot := metricsCollector.GetMetrics()
+		batch := deltas.Deltas(snapshot)
+		var fromStatsD []models.Metrics
+		if statsdListener != nil {
+			fromStatsD = statsdListener.Flush()
+			batch = append(batch, fromStatsD...)
+		}
+		queued := false
+		for _, u := range upstreams {
+			if err := u.queue.Push(batch); err != nil {
+				log.Printf("Failed to enqueue metrics batch for %s: %v", u.name, err)
+				continue
+			}
+			queued = true
+		}
+		if !queued {
+			if statsdListener != nil {
+				statsdListener.Restore(fromStatsD)
+			}
+			return
+		}
+		deltas.Ack(snapshot)
+		metricsCollector.ResetWindow()
+	}
+
+	// Режим pull: агент отдает текущий снимок метрик, не влияя на отправку
+	var pullSrv *http.Server
+	if cfg.ListenAddr != "" {
+		pullSrv = pull.NewServer(cfg.ListenAddr, metricsCollector.Snapshot)
+		go func() {
+			if err := pullSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Pull server error: %v", err)
 			}
 		}()
+		log.Printf("Serving agent metrics on http://%s/metrics", cfg.ListenAddr)
 	}
 
+	var drainers sync.WaitGroup
+	for _, u := range upstreams {
+		drainers.Add(1)
+		go u.run(ctx, &drainers)
+	}
+
+	var pollers sync.WaitGroup
+	pollers.Add(2)
 	go func() {
-		for range time.Tick(cfg.PollInterval) {
-			metricsCollector.Update()
+		defer pollers.Done()
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := metricsCollector.Update(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("Failed to collect metrics: %v", err)
+				}
+			}
 		}
 	}()
 
 	go func() {
-		for range time.Tick(cfg.ReportInterval) {
-			metricsSnapshot := metricsCollector.GetMetrics()
+		defer pollers.Done()
+		ticker := time.NewTicker(cfg.ReportInterval)
+		defer ticker.Stop()
+		for {
 			select {
-			case metricsChan <- metricsSnapshot:
-			default:
-				log.Println("Rate limit exceeded, skipping metrics send")
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report()
+				for _, u := range upstreams {
+					u.notify()
+				}
 			}
 		}
 	}()
 
-	<-done
+	<-ctx.Done()
+	log.Println("Agent is shutting down...")
+
+	// Останавливаем опрос и отправку; прерванный пакет остается в очереди
+	// и будет отправлен повторно с тем же ключом идемпотентности
+	pollers.Wait()
+	if pullSrv != nil {
+		pullSrv.Close()
+	}
+	if statsdListener != nil {
+		statsdListener.Close()
+	}
+	for _, u := range upstreams {
+		close(u.flush)
+	}
+	drainers.Wait()
 
-	close(metricsChan)
-	wg.Wait()
+	// Метрики, собранные с последней отправки, отправляются на все серверы
+	// параллельно с общим ограничением по времени
+	report()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	var final sync.WaitGroup
+	for _, u := range upstreams {
+		final.Add(1)
+		go func(u *upstream) {
+			defer final.Done()
+			if err := u.drain(shutdownCtx); err != nil {
+				log.Printf("Failed to send metrics to %s on shutdown, %d batches left in outbox: %v", u.name, u.queue.Len(), err)
+			} else {
+				log.Printf("Metrics sent successfully to %s on shutdown", u.name)
+			}
+			u.close()
+		}(u)
+	}
+	final.Wait()
+	log.Println("Agent stopped")
 }
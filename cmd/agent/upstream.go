@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/agent/outbox"
+	"go-metrics-server/internal/agent/sender"
+	"go-metrics-server/internal/encryption"
+	"go-metrics-server/internal/models"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ServerLabel — метка с адресом сервера в метриках доставки агента.
+const ServerLabel = "server"
+
+// upstream — направление доставки: отправитель и собственная очередь пакетов.
+// В режиме fanout у каждого сервера свой upstream, поэтому доставка
+// отслеживается для каждого сервера отдельно. В режиме failover upstream
+// один, а сервер выбирает sender.Failover.
+type upstream struct {
+	name   string
+	sender sender.MetricsSender
+	queue  *outbox.Outbox
+	flush  chan struct{}
+	limit  chan struct{} // Общий для всех направлений семафор одновременных отправок
+}
+
+// newUpstreams создает направления доставки по конфигурации и возвращает
+// автоматы защиты HTTP-отправителей по адресам серверов.
+// Число одновременных отправок по всем направлениям ограничено RateLimit.
+func newUpstreams(cfg *config.Config) ([]*upstream, map[string]*sender.CircuitBreaker, error) {
+	limit := make(chan struct{}, max(cfg.RateLimit, 1))
+	breakers := make(map[string]*sender.CircuitBreaker)
+	senders := make([]sender.MetricsSender, len(cfg.Servers))
+	for i, addr := range cfg.Servers {
+		s, err := newSender(cfg, addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if httpSender, ok := s.(*sender.Sender); ok && httpSender.Breaker != nil {
+			breakers[addr] = httpSender.Breaker
+		}
+		senders[i] = s
+	}
+
+	if cfg.ServerMode == "failover" || len(cfg.Servers) == 1 {
+		var s sender.MetricsSender = senders[0]
+		if len(senders) > 1 {
+			s = sender.NewFailover(senders...)
+		}
+		u, err := newUpstream(strings.Join(cfg.Servers, ","), s, cfg.OutboxPath, cfg.OutboxSize, limit)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*upstream{u}, breakers, nil
+	}
+
+	upstreams := make([]*upstream, len(cfg.Servers))
+	for i, addr := range cfg.Servers {
+		dir := cfg.OutboxPath
+		if dir != "" {
+			dir = filepath.Join(dir, queueDirName(addr))
+		}
+		u, err := newUpstream(addr, senders[i], dir, cfg.OutboxSize, limit)
+		if err != nil {
+			return nil, nil, err
+		}
+		upstreams[i] = u
+	}
+	return upstreams, breakers, nil
+}
+
+func newUpstream(name string, s sender.MetricsSender, dir string, size int, limit chan struct{}) (*upstream, error) {
+	queue, err := outbox.Open(dir, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox for %s: %w", name, err)
+	}
+	if n := queue.Len(); n > 0 {
+		log.Printf("Replaying %d unsent batches for %s", n, name)
+	}
+
+	u := &upstream{name: name, sender: s, queue: queue, flush: make(chan struct{}, 1), limit: limit}
+	u.notify()
+	return u, nil
+}
+
+var unsafeDirChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// queueDirName возвращает имя каталога очереди для адреса сервера.
+func queueDirName(addr string) string {
+	return unsafeDirChars.ReplaceAllString(addr, "_")
+}
+
+// run отправляет пакеты из очереди по сигналам notify, пока не закрыт flush.
+// Пакеты отправляются по одному, чтобы сохранить их порядок.
+func (u *upstream) run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for range u.flush {
+		if err := u.drain(ctx); err != nil {
+			log.Printf("Failed to send metrics batch to %s, %d batches queued: %v", u.name, u.queue.Len(), err)
+		}
+	}
+}
+
+// notify запускает отправку, если она еще не запланирована.
+func (u *upstream) notify() {
+	select {
+	case u.flush <- struct{}{}:
+	default:
+	}
+}
+
+// drain отправляет пакеты из очереди, пока она не опустеет,
+// не произойдет ошибка или не будет отменен ctx.
+func (u *upstream) drain(ctx context.Context) error {
+	return u.queue.Drain(func(batch models.Batch) error {
+		select {
+		case u.limit <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-u.limit }()
+		return u.sender.SendBatch(ctx, batch)
+	})
+}
+
+func (u *upstream) close() {
+	if closer, ok := u.sender.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// newSender создает отправителя метрик на addr для выбранного в конфигурации протокола.
+func newSender(cfg *config.Config, addr string) (sender.MetricsSender, error) {
+	if cfg.Transport == "grpc" {
+		s, err := sender.NewGRPC(addr)
+		if err != nil {
+			return nil, err
+		}
+		s.AgentID = cfg.AgentID
+		s.Key = cfg.Key
+		return s, nil
+	}
+
+	s := sender.New(addr, cfg.Key)
+	s.AgentID = cfg.AgentID
+	if cfg.CryptoKey != "" {
+		publicKey, err := encryption.LoadPublicKey(cfg.CryptoKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load crypto key: %w", err)
+		}
+		s.PublicKey = publicKey
+	}
+	return s, nil
+}
@@ -2,19 +2,27 @@ package main
 
 import (
 	"context"
+	"go-metrics-server/internal/models"
 	"go-metrics-server/internal/server/config"
 	"go-metrics-server/internal/server/database"
+	"go-metrics-server/internal/server/grpcserver"
 	"go-metrics-server/internal/server/repository"
 	"go-metrics-server/internal/server/webservers"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
+// historyPruneInterval — период удаления устаревшей истории значений.
+const historyPruneInterval = time.Hour
+
 func main() {
 	// Создаем основной контекст приложения
 	ctx, cancel := context.WithCancel(context.Background())
@@ -75,7 +83,31 @@ func main() {
 		}
 	}
 
-	srv := webservers.NewServer(cfg, repo, db)
+	// Удаление устаревшей истории значений
+	if cfg.HistoryRetention > 0 {
+		pruneTicker := time.NewTicker(historyPruneInterval)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-pruneTicker.C:
+					pruned, err := repo.PruneHistory(ctx, time.Now().Add(-cfg.HistoryRetention))
+					if err != nil {
+						log.Printf("Failed to prune metric history: %v\n", err)
+					} else if pruned > 0 {
+						log.Printf("Pruned %d history points\n", pruned)
+					}
+				}
+			}
+		}()
+		defer pruneTicker.Stop()
+	}
+
+	srv, err := webservers.NewServer(cfg, repo, db)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v\n", err)
+	}
 	log.Printf("Server is running on http://%s\n", cfg.ServerAddr)
 
 	// Обработка graceful shutdown
@@ -88,9 +120,32 @@ func main() {
 		}
 	}()
 
+	var grpcSrv *grpc.Server
+	if cfg.GRPCAddr != "" {
+		listener, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen gRPC address: %v\n", err)
+		}
+		grpcSrv, err = grpcserver.NewServer(cfg, repo)
+		if err != nil {
+			log.Fatalf("Failed to create gRPC server: %v\n", err)
+		}
+		log.Printf("gRPC server is running on %s\n", cfg.GRPCAddr)
+
+		go func() {
+			if err := grpcSrv.Serve(listener); err != nil {
+				log.Fatalf("gRPC server error: %v\n", err)
+			}
+		}()
+	}
+
 	<-done
 	log.Println("Server is shutting down...")
 
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
+
 	// Сохранение данных перед выходом (если не используется БД)
 	if cfg.DatabaseDSN == "" && cfg.FileStorage != "" {
 		if err := repo.SaveToFile(ctx, cfg.FileStorage); err != nil {
@@ -177,6 +232,24 @@ func (s *syncSaveRepository) UpdateCounter(ctx context.Context, name string, val
 	return err
 }
 
+func (s *syncSaveRepository) UpdateMetrics(ctx context.Context, metrics []models.Metrics) error {
+	err := s.MetricRepository.UpdateMetrics(ctx, metrics)
+	for _, metric := range metrics {
+		s.bufferUpdate(models.SeriesName(metric.ID, metric.Labels), metric)
+	}
+	return err
+}
+
+func (s *syncSaveRepository) UpdateMetricsIdempotent(ctx context.Context, key string, metrics []models.Metrics, response []byte) ([]byte, bool, error) {
+	stored, duplicate, err := s.MetricRepository.UpdateMetricsIdempotent(ctx, key, metrics, response)
+	if err == nil && !duplicate {
+		for _, metric := range metrics {
+			s.bufferUpdate(models.SeriesName(metric.ID, metric.Labels), metric)
+		}
+	}
+	return stored, duplicate, err
+}
+
 func (s *syncSaveRepository) bufferUpdate(name string, value interface{}) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
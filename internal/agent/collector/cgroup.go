@@ -0,0 +1,259 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterFactory("cgroup", func(cfg *config.Config) (Collector, error) { return NewCgroup(cfg), nil })
+}
+
+// CgroupLabel — метка с путем cgroup относительно корня иерархии.
+const CgroupLabel = "cgroup"
+
+// Cgroup читает потребление ресурсов из файлов cgroup v2: память, процессор,
+// ввод-вывод и число процессов. В контейнере эти значения, в отличие от
+// коллекторов memory и cpu, относятся к самому контейнеру. Файлы
+// отключенных контроллеров пропускаются.
+type Cgroup struct {
+	root       string
+	paths      []string
+	selfCgroup string // Файл с cgroup текущего процесса
+	rates      *rateTracker
+	now        func() time.Time
+}
+
+func NewCgroup(cfg *config.Config) *Cgroup {
+	c := &Cgroup{
+		root:       cfg.CgroupRoot,
+		selfCgroup: "/proc/self/cgroup",
+		rates:      newRateTracker(),
+		now:        time.Now,
+	}
+	for _, p := range cfg.CgroupPaths {
+		// Путь можно указать и вместе с корнем иерархии
+		c.paths = append(c.paths, path.Clean("/"+strings.TrimPrefix(p, c.root)))
+	}
+	return c
+}
+
+func (c *Cgroup) Name() string { return "cgroup" }
+
+// CountersSinceBoot сообщает, что счетчики накоплены с создания cgroup.
+func (c *Cgroup) CountersSinceBoot() bool { return true }
+
+func (c *Cgroup) Collect(ctx context.Context) ([]models.Metrics, error) {
+	paths := c.paths
+	if len(paths) == 0 {
+		own, err := c.ownCgroup()
+		if err != nil {
+			return nil, err
+		}
+		paths = []string{own}
+	}
+
+	now := c.now()
+	var metrics []models.Metrics
+	var errs []error
+	for _, p := range paths {
+		collected, err := c.collectCgroup(p, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cgroup %s: %w", p, err))
+		}
+		metrics = append(metrics, collected...)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// ownCgroup возвращает cgroup текущего процесса из строки вида "0::/path".
+func (c *Cgroup) ownCgroup() (string, error) {
+	data, err := os.ReadFile(c.selfCgroup)
+	if err != nil {
+		return "", fmt.Errorf("failed to read own cgroup: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if p, ok := strings.CutPrefix(line, "0::"); ok {
+			return p, nil
+		}
+	}
+	return "", errors.New("cgroup v2 is not used by the agent process")
+}
+
+func (c *Cgroup) collectCgroup(p string, now time.Time) ([]models.Metrics, error) {
+	dir := filepath.Join(c.root, filepath.FromSlash(p))
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	labels := map[string]string{CgroupLabel: p}
+
+	var metrics []models.Metrics
+	var errs []error
+	add := func(collected []models.Metrics, err error) {
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		metrics = append(metrics, collected...)
+	}
+	add(c.memory(dir, labels))
+	add(c.cpu(dir, labels, now))
+	add(c.io(dir, labels, now))
+	add(c.pids(dir, labels))
+	return metrics, errors.Join(errs...)
+}
+
+func (c *Cgroup) memory(dir string, labels map[string]string) ([]models.Metrics, error) {
+	current, err := readCgroupValue(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return nil, err
+	}
+	metrics := []models.Metrics{withLabels(gauge("CgroupMemoryCurrent", float64(current)), labels)}
+
+	limit, err := readCgroupValue(filepath.Join(dir, "memory.max"))
+	if err != nil {
+		return metrics, ignoreUnlimited(err)
+	}
+	metrics = append(metrics, withLabels(gauge("CgroupMemoryMax", float64(limit)), labels))
+	if limit > 0 {
+		metrics = append(metrics, withLabels(gauge("CgroupMemoryUsedPercent", float64(current)/float64(limit)*100), labels))
+	}
+	return metrics, nil
+}
+
+func (c *Cgroup) cpu(dir string, labels map[string]string, now time.Time) ([]models.Metrics, error) {
+	stat, err := readCgroupKeyed(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Значения накоплены с создания cgroup, поэтому первые полученные
+	// значения становятся базовыми для дельт (CountersSinceBoot)
+	var metrics []models.Metrics
+	for _, v := range []struct{ key, id string }{
+		{"usage_usec", "CgroupCPUUsageUsec"},
+		{"user_usec", "CgroupCPUUserUsec"},
+		{"system_usec", "CgroupCPUSystemUsec"},
+		{"nr_throttled", "CgroupCPUThrottledPeriods"},
+		{"throttled_usec", "CgroupCPUThrottledUsec"},
+	} {
+		if value, ok := stat[v.key]; ok {
+			metrics = append(metrics, withLabels(counter(v.id, int64(value)), labels))
+		}
+	}
+	// Загрузка в процентах одного процессора, как у top
+	if usage, ok := stat["usage_usec"]; ok {
+		if rate, ok := c.rates.rate("CgroupCPUUsageUsec"+models.LabelsKey(labels), usage, now); ok {
+			metrics = append(metrics, withLabels(gauge("CgroupCPUPercent", rate/1e4), labels))
+		}
+	}
+	return metrics, nil
+}
+
+func (c *Cgroup) io(dir string, labels map[string]string, now time.Time) ([]models.Metrics, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []models.Metrics
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		stat := make(map[string]uint64, len(fields)-1)
+		for _, field := range fields[1:] {
+			k, v, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			if value, err := strconv.ParseUint(v, 10, 64); err == nil {
+				stat[k] = value
+			}
+		}
+
+		deviceLabels := map[string]string{CgroupLabel: labels[CgroupLabel], DeviceLabel: fields[0]}
+		metrics = append(metrics, rateMetrics(c.rates, deviceLabels, now, []counterValue{
+			{"CgroupIOReadBytes", stat["rbytes"]},
+			{"CgroupIOWriteBytes", stat["wbytes"]},
+			{"CgroupIOReads", stat["rios"]},
+			{"CgroupIOWrites", stat["wios"]},
+		})...)
+	}
+	return metrics, scanner.Err()
+}
+
+func (c *Cgroup) pids(dir string, labels map[string]string) ([]models.Metrics, error) {
+	current, err := readCgroupValue(filepath.Join(dir, "pids.current"))
+	if err != nil {
+		return nil, err
+	}
+	metrics := []models.Metrics{withLabels(gauge("CgroupPids", float64(current)), labels)}
+
+	limit, err := readCgroupValue(filepath.Join(dir, "pids.max"))
+	if err != nil {
+		return metrics, ignoreUnlimited(err)
+	}
+	return append(metrics, withLabels(gauge("CgroupPidsMax", float64(limit)), labels)), nil
+}
+
+// errUnlimited означает, что в файле ограничения записано "max".
+var errUnlimited = errors.New("unlimited")
+
+// readCgroupValue читает файл с одним числом. Для ограничения "max"
+// возвращается errUnlimited.
+func readCgroupValue(name string) (uint64, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, errUnlimited
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %s: %w", filepath.Base(name), err)
+	}
+	return n, nil
+}
+
+// readCgroupKeyed читает файл со строками вида "key value".
+func readCgroupKeyed(name string) (map[string]uint64, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			result[fields[0]] = value
+		}
+	}
+	return result, nil
+}
+
+// ignoreUnlimited скрывает отсутствие ограничения и отсутствие файла
+// ограничения: у корневой cgroup его нет.
+func ignoreUnlimited(err error) error {
+	if errors.Is(err, errUnlimited) || errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-metrics-server/internal/agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cgroupFixture = "testdata/cgroup"
+
+func TestCgroup_Own(t *testing.T) {
+	c := NewCgroup(&config.Config{CgroupRoot: cgroupFixture})
+	c.selfCgroup = "testdata/self-cgroup"
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	byName := metricsByName(metrics)
+
+	const labels = `{cgroup="/system.slice/app.service"}`
+	assert.Equal(t, 52428800.0, *byName["CgroupMemoryCurrent"+labels].Value)
+	// Без ограничения memory.max и pids.max не передаются
+	assert.NotContains(t, byName, "CgroupMemoryMax"+labels)
+	assert.NotContains(t, byName, "CgroupPidsMax"+labels)
+	assert.Equal(t, 7.0, *byName["CgroupPids"+labels].Value)
+
+	usage := byName["CgroupCPUUsageUsec"+labels]
+	assert.Equal(t, "counter", usage.MType)
+	assert.Equal(t, int64(2000000), *usage.Delta)
+	assert.Equal(t, int64(500000), *byName["CgroupCPUSystemUsec"+labels].Delta)
+	assert.NotContains(t, byName, "CgroupCPUPercent"+labels)
+
+	const device = `{cgroup="/system.slice/app.service",device="8:0"}`
+	assert.Equal(t, int64(4096), *byName["CgroupIOReadBytes"+device].Delta)
+	assert.Equal(t, int64(2), *byName["CgroupIOWrites"+device].Delta)
+	assert.Equal(t, int64(3), *byName[`CgroupIOReads{cgroup="/system.slice/app.service",device="253:1"}`].Delta)
+}
+
+func TestCgroup_Paths(t *testing.T) {
+	c := NewCgroup(&config.Config{CgroupRoot: cgroupFixture, CgroupPaths: []string{"batch", cgroupFixture + "/system.slice/app.service"}})
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	byName := metricsByName(metrics)
+
+	assert.Contains(t, byName, `CgroupMemoryCurrent{cgroup="/system.slice/app.service"}`)
+	assert.Equal(t, 536870912.0, *byName[`CgroupMemoryMax{cgroup="/batch"}`].Value)
+	assert.Equal(t, 50.0, *byName[`CgroupMemoryUsedPercent{cgroup="/batch"}`].Value)
+	assert.Equal(t, 100.0, *byName[`CgroupPidsMax{cgroup="/batch"}`].Value)
+	assert.Equal(t, int64(3), *byName[`CgroupCPUThrottledPeriods{cgroup="/batch"}`].Delta)
+	// Контроллер io у batch не включен
+	assert.NotContains(t, byName, `CgroupIOReadBytes{cgroup="/batch",device="8:0"}`)
+}
+
+func TestCgroup_CPUPercent(t *testing.T) {
+	root := t.TempDir()
+	stat := filepath.Join(root, "cpu.stat")
+	require.NoError(t, os.WriteFile(stat, []byte("usage_usec 1000000\n"), 0o644))
+
+	c := NewCgroup(&config.Config{CgroupRoot: root, CgroupPaths: []string{"/"}})
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+	_, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	// За 2 секунды израсходовано 1.5 секунды процессорного времени
+	require.NoError(t, os.WriteFile(stat, []byte("usage_usec 2500000\n"), 0o644))
+	now = now.Add(2 * time.Second)
+	metrics, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.InDelta(t, 75.0, *metricsByName(metrics)[`CgroupCPUPercent{cgroup="/"}`].Value, 1e-9)
+}
+
+func TestCgroup_Errors(t *testing.T) {
+	c := NewCgroup(&config.Config{CgroupRoot: cgroupFixture, CgroupPaths: []string{"missing", "batch"}})
+	metrics, err := c.Collect(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cgroup /missing")
+	assert.NotEmpty(t, metrics)
+
+	c = NewCgroup(&config.Config{CgroupRoot: cgroupFixture})
+	c.selfCgroup = filepath.Join(t.TempDir(), "cgroup")
+	require.NoError(t, os.WriteFile(c.selfCgroup, []byte("12:memory:/docker/abc\n"), 0o644))
+	_, err = c.Collect(context.Background())
+	assert.ErrorContains(t, err, "cgroup v2")
+}
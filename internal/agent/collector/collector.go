@@ -0,0 +1,188 @@
+// Package collector описывает источники метрик агента. Каждый коллектор
+// собирает свой набор метрик, а реестр опрашивает все включенные коллекторы.
+// Встроенные коллекторы регистрируются в этом пакете; дополнительные можно
+// подключить через RegisterFactory, не изменяя код агента.
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+	"sort"
+	"sync"
+)
+
+// Collector — источник метрик агента.
+type Collector interface {
+	// Name возвращает имя коллектора, по которому он включается в конфигурации.
+	Name() string
+	// Collect возвращает текущие значения метрик. Значения счетчиков
+	// накопительные: дельты для сервера вычисляет агент.
+	Collect(ctx context.Context) ([]models.Metrics, error)
+}
+
+// SinceBootCollector — коллектор, счетчики которого накоплены до запуска
+// агента: с загрузки системы или создания cgroup. Первые значения его
+// счетчиков агент считает базовыми и не отправляет серверу как прирост.
+// Счетчики остальных коллекторов отправляются целиком, в каком бы опросе
+// они ни появились.
+type SinceBootCollector interface {
+	Collector
+	CountersSinceBoot() bool
+}
+
+// Factory создает коллектор по конфигурации агента.
+type Factory func(cfg *config.Config) (Collector, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// RegisterFactory регистрирует коллектор под именем name. Повторная
+// регистрация имени считается ошибкой программы и вызывает панику.
+func RegisterFactory(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("collector %q already registered", name))
+	}
+	factories[name] = factory
+}
+
+// Available возвращает отсортированные имена зарегистрированных коллекторов.
+func Available() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Registry — набор включенных коллекторов.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry создает реестр из переданных коллекторов.
+func NewRegistry(collectors ...Collector) *Registry {
+	r := &Registry{}
+	for _, c := range collectors {
+		r.Register(c)
+	}
+	return r
+}
+
+// FromConfig создает реестр из коллекторов, перечисленных в cfg.Collectors.
+func FromConfig(cfg *config.Config) (*Registry, error) {
+	r := NewRegistry()
+	for _, name := range cfg.Collectors {
+		factoriesMu.RLock()
+		factory, ok := factories[name]
+		factoriesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+
+		c, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create collector %q: %w", name, err)
+		}
+		r.Register(c)
+	}
+	return r, nil
+}
+
+// Register добавляет коллектор в реестр.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Names возвращает имена коллекторов в порядке регистрации.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, len(r.collectors))
+	for i, c := range r.collectors {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// Collect опрашивает все коллекторы. Ошибка одного коллектора не мешает
+// остальным: возвращаются собранные метрики и объединенная ошибка.
+func (r *Registry) Collect(ctx context.Context) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+	var errs []error
+	for _, result := range r.CollectEach(ctx) {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+		metrics = append(metrics, result.Metrics...)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// Result — результат опроса одного коллектора.
+type Result struct {
+	Metrics   []models.Metrics
+	Err       error // Ошибка с именем коллектора
+	SinceBoot bool  // Счетчики накоплены до запуска агента (SinceBootCollector)
+}
+
+// CollectEach опрашивает все коллекторы и возвращает результаты каждого
+// в порядке регистрации. Коллекторы только добавляются в реестр, поэтому
+// номер результата соответствует одному и тому же коллектору между опросами.
+func (r *Registry) CollectEach(ctx context.Context) []Result {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	results := make([]Result, len(collectors))
+	for i, c := range collectors {
+		collected, err := c.Collect(ctx)
+		if err != nil {
+			err = fmt.Errorf("collector %s: %w", c.Name(), err)
+		}
+		sinceBoot, ok := c.(SinceBootCollector)
+		results[i] = Result{Metrics: collected, Err: err, SinceBoot: ok && sinceBoot.CountersSinceBoot()}
+	}
+	return results
+}
+
+// funcCollector — коллектор, заданный функцией.
+type funcCollector struct {
+	name    string
+	collect func(ctx context.Context) ([]models.Metrics, error)
+}
+
+// NewFunc создает коллектор с именем name, который возвращает результат collect.
+// Удобен для метрик состояния самого агента.
+func NewFunc(name string, collect func(ctx context.Context) ([]models.Metrics, error)) Collector {
+	return funcCollector{name: name, collect: collect}
+}
+
+func (c funcCollector) Name() string { return c.name }
+
+func (c funcCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	return c.collect(ctx)
+}
+
+func gauge(id string, value float64) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &value}
+}
+
+func counter(id string, value int64) models.Metrics {
+	return models.Metrics{ID: id, MType: "counter", Delta: &value}
+}
@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubCollector struct {
+	name    string
+	metrics []models.Metrics
+	err     error
+}
+
+func (c stubCollector) Name() string { return c.name }
+
+func (c stubCollector) Collect(context.Context) ([]models.Metrics, error) {
+	return c.metrics, c.err
+}
+
+func TestRegistry_Collect(t *testing.T) {
+	registry := NewRegistry(
+		stubCollector{name: "a", metrics: []models.Metrics{gauge("A", 1)}},
+		stubCollector{name: "broken", err: errors.New("unavailable")},
+	)
+	registry.Register(stubCollector{name: "b", metrics: []models.Metrics{counter("B", 2)}})
+	assert.Equal(t, []string{"a", "broken", "b"}, registry.Names())
+
+	// Ошибка одного коллектора не мешает остальным
+	metrics, err := registry.Collect(context.Background())
+	assert.ErrorContains(t, err, "collector broken: unavailable")
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "A", metrics[0].ID)
+	assert.Equal(t, "B", metrics[1].ID)
+
+	// Результаты по коллекторам идут в порядке регистрации
+	results := registry.CollectEach(context.Background())
+	require.Len(t, results, 3)
+	assert.Equal(t, "A", results[0].Metrics[0].ID)
+	assert.ErrorContains(t, results[1].Err, "collector broken")
+	assert.NoError(t, results[2].Err)
+	assert.False(t, results[2].SinceBoot)
+
+	// Коллекторы со счетчиками с загрузки системы отмечаются в результате
+	registry.Register(NewDiskIO())
+	assert.True(t, registry.CollectEach(context.Background())[3].SinceBoot)
+}
+
+func TestFromConfig(t *testing.T) {
+	assert.Subset(t, Available(), []string{"cpu", "memory", "random", "runtime"})
+
+	registry, err := FromConfig(&config.Config{Collectors: []string{"runtime", "random"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"runtime", "random"}, registry.Names())
+
+	_, err = FromConfig(&config.Config{Collectors: []string{"unknown"}})
+	assert.Error(t, err)
+}
+
+func TestRegisterFactory(t *testing.T) {
+	RegisterFactory("test-custom", func(*config.Config) (Collector, error) {
+		return stubCollector{name: "test-custom", metrics: []models.Metrics{gauge("Custom", 3)}}, nil
+	})
+	assert.Panics(t, func() {
+		RegisterFactory("test-custom", func(*config.Config) (Collector, error) { return nil, nil })
+	})
+
+	registry, err := FromConfig(&config.Config{Collectors: []string{"test-custom"}})
+	require.NoError(t, err)
+	metrics, err := registry.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, *metrics[0].Value)
+}
+
+func TestRuntime_Collect(t *testing.T) {
+	metrics, err := Runtime{}.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, metrics, 27)
+	for _, m := range metrics {
+		assert.Equal(t, "gauge", m.MType)
+	}
+}
+
+func TestNameFilter(t *testing.T) {
+	filter, err := newNameFilter([]string{"/", "/data*"}, []string{"/data/tmp"})
+	require.NoError(t, err)
+	assert.True(t, filter.match("/"))
+	assert.True(t, filter.match("/data1"))
+	assert.False(t, filter.match("/data/tmp"))
+	assert.False(t, filter.match("/boot"))
+
+	// Без include разрешены все имена, кроме исключенных
+	filter, err = newNameFilter(nil, []string{"lo", "docker*"})
+	require.NoError(t, err)
+	assert.True(t, filter.match("eth0"))
+	assert.False(t, filter.match("docker0"))
+
+	_, err = newNameFilter([]string{"[invalid"}, nil)
+	assert.Error(t, err)
+}
+
+func TestRateTracker(t *testing.T) {
+	rates := newRateTracker()
+	start := time.Unix(0, 0)
+
+	_, ok := rates.rate("a", 100, start)
+	assert.False(t, ok)
+
+	rate, ok := rates.rate("a", 300, start.Add(2*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, rate)
+
+	// После сброса счетчика скорость неизвестна до следующего опроса
+	_, ok = rates.rate("a", 10, start.Add(3*time.Second))
+	assert.False(t, ok)
+	rate, ok = rates.rate("a", 20, start.Add(4*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 10.0, rate)
+}
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"context"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func init() {
+	RegisterFactory("filesystem", func(cfg *config.Config) (Collector, error) { return NewFilesystem(cfg) })
+	RegisterFactory("diskio", func(*config.Config) (Collector, error) { return NewDiskIO(), nil })
+}
+
+// MountpointLabel — метка с точкой монтирования файловой системы.
+const MountpointLabel = "mountpoint"
+
+// DeviceLabel — метка с именем блочного устройства.
+const DeviceLabel = "device"
+
+// Filesystem собирает заполненность смонтированных файловых систем.
+// Точки монтирования отбираются шаблонами MountInclude и MountExclude.
+type Filesystem struct {
+	filter     nameFilter
+	partitions func(ctx context.Context) ([]disk.PartitionStat, error)
+	usage      func(ctx context.Context, path string) (*disk.UsageStat, error)
+}
+
+func NewFilesystem(cfg *config.Config) (*Filesystem, error) {
+	filter, err := newNameFilter(cfg.MountInclude, cfg.MountExclude)
+	if err != nil {
+		return nil, err
+	}
+	return &Filesystem{
+		filter: filter,
+		partitions: func(ctx context.Context) ([]disk.PartitionStat, error) {
+			return disk.PartitionsWithContext(ctx, false)
+		},
+		usage: disk.UsageWithContext,
+	}, nil
+}
+
+func (f *Filesystem) Name() string { return "filesystem" }
+
+func (f *Filesystem) Collect(ctx context.Context) ([]models.Metrics, error) {
+	partitions, err := f.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []models.Metrics
+	seen := make(map[string]bool)
+	for _, p := range partitions {
+		if seen[p.Mountpoint] || !f.filter.match(p.Mountpoint) {
+			continue
+		}
+		seen[p.Mountpoint] = true
+
+		// Недоступная точка монтирования (например, отключенный сетевой диск)
+		// не должна мешать остальным
+		usage, err := f.usage(ctx, p.Mountpoint)
+		if err != nil {
+			continue
+		}
+		labels := map[string]string{MountpointLabel: p.Mountpoint}
+		metrics = append(metrics,
+			withLabels(gauge("FsTotalBytes", float64(usage.Total)), labels),
+			withLabels(gauge("FsUsedBytes", float64(usage.Used)), labels),
+			withLabels(gauge("FsFreeBytes", float64(usage.Free)), labels),
+			withLabels(gauge("FsUsedPercent", usage.UsedPercent), labels),
+			withLabels(gauge("FsInodesUsedPercent", usage.InodesUsedPercent), labels),
+		)
+	}
+	return metrics, nil
+}
+
+// DiskIO собирает счетчики ввода-вывода блочных устройств и их скорость
+// между опросами.
+type DiskIO struct {
+	ioCounters func(ctx context.Context) (map[string]disk.IOCountersStat, error)
+	rates      *rateTracker
+	now        func() time.Time
+}
+
+func NewDiskIO() *DiskIO {
+	return &DiskIO{
+		ioCounters: func(ctx context.Context) (map[string]disk.IOCountersStat, error) {
+			return disk.IOCountersWithContext(ctx)
+		},
+		rates: newRateTracker(),
+		now:   time.Now,
+	}
+}
+
+func (d *DiskIO) Name() string { return "diskio" }
+
+// CountersSinceBoot сообщает, что счетчики устройств накоплены с загрузки системы.
+func (d *DiskIO) CountersSinceBoot() bool { return true }
+
+func (d *DiskIO) Collect(ctx context.Context) ([]models.Metrics, error) {
+	counters, err := d.ioCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.now()
+	var metrics []models.Metrics
+	for device, c := range counters {
+		labels := map[string]string{DeviceLabel: device}
+		metrics = append(metrics, rateMetrics(d.rates, labels, now, []counterValue{
+			{"DiskReadBytes", c.ReadBytes},
+			{"DiskWriteBytes", c.WriteBytes},
+			{"DiskReads", c.ReadCount},
+			{"DiskWrites", c.WriteCount},
+		})...)
+	}
+	return metrics, nil
+}
+
+// counterValue — накопительное значение, для которого считается скорость.
+type counterValue struct {
+	id    string
+	value uint64
+}
+
+// rateMetrics возвращает для каждого значения счетчик id и, если скорость
+// уже известна, gauge idPerSec с теми же метками.
+func rateMetrics(rates *rateTracker, labels map[string]string, now time.Time, values []counterValue) []models.Metrics {
+	key := models.LabelsKey(labels)
+	var metrics []models.Metrics
+	for _, v := range values {
+		metrics = append(metrics, withLabels(counter(v.id, int64(v.value)), labels))
+		if rate, ok := rates.rate(v.id+key, v.value, now); ok {
+			metrics = append(metrics, withLabels(gauge(v.id+"PerSec", rate), labels))
+		}
+	}
+	return metrics
+}
+
+func withLabels(metric models.Metrics, labels map[string]string) models.Metrics {
+	metric.Labels = labels
+	return metric
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricsByName индексирует метрики по имени ряда.
+func metricsByName(metrics []models.Metrics) map[string]models.Metrics {
+	result := make(map[string]models.Metrics, len(metrics))
+	for _, m := range metrics {
+		result[models.SeriesName(m.ID, m.Labels)] = m
+	}
+	return result
+}
+
+func TestFilesystem_Collect(t *testing.T) {
+	fs, err := NewFilesystem(&config.Config{MountExclude: []string{"/boot", "/boot/*"}})
+	require.NoError(t, err)
+	fs.partitions = func(context.Context) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{
+			{Mountpoint: "/"}, {Mountpoint: "/boot/efi"}, {Mountpoint: "/mnt/nfs"}, {Mountpoint: "/"},
+		}, nil
+	}
+	fs.usage = func(_ context.Context, path string) (*disk.UsageStat, error) {
+		if path == "/mnt/nfs" {
+			return nil, errors.New("stale file handle")
+		}
+		return &disk.UsageStat{Total: 100, Used: 40, Free: 60, UsedPercent: 40}, nil
+	}
+
+	metrics, err := fs.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, metrics, 5)
+
+	byName := metricsByName(metrics)
+	assert.Equal(t, 100.0, *byName[`FsTotalBytes{mountpoint="/"}`].Value)
+	assert.Equal(t, 40.0, *byName[`FsUsedPercent{mountpoint="/"}`].Value)
+}
+
+func TestDiskIO_Collect(t *testing.T) {
+	d := NewDiskIO()
+	reads := uint64(1000)
+	d.ioCounters = func(context.Context) (map[string]disk.IOCountersStat, error) {
+		return map[string]disk.IOCountersStat{"sda": {ReadBytes: reads, ReadCount: 10}}, nil
+	}
+	now := time.Unix(0, 0)
+	d.now = func() time.Time { return now }
+
+	// Первый опрос — только накопительные счетчики
+	metrics, err := d.Collect(context.Background())
+	require.NoError(t, err)
+	byName := metricsByName(metrics)
+	assert.Equal(t, int64(1000), *byName[`DiskReadBytes{device="sda"}`].Delta)
+	assert.NotContains(t, byName, `DiskReadBytesPerSec{device="sda"}`)
+
+	reads = 6000
+	now = now.Add(10 * time.Second)
+	metrics, err = d.Collect(context.Background())
+	require.NoError(t, err)
+	byName = metricsByName(metrics)
+	assert.Equal(t, 500.0, *byName[`DiskReadBytesPerSec{device="sda"}`].Value)
+	assert.Equal(t, 0.0, *byName[`DiskReadsPerSec{device="sda"}`].Value)
+}
@@ -0,0 +1,210 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterFactory("exec", func(cfg *config.Config) (Collector, error) { return NewExec(cfg), nil })
+}
+
+// CommandLabel — метка с именем внешней команды.
+const CommandLabel = "command"
+
+// Exec запускает внешние команды и разбирает их стандартный вывод как
+// метрики: JSON-массив models.Metrics или строки вида "name type value";
+// поддерживаются типы gauge и counter. Как и у встроенных коллекторов, значения
+// счетчиков накопительные. Все метрики команды получают метку command с ее
+// именем из конфигурации, поэтому одинаковые имена метрик разных команд не
+// смешиваются. Для каждой команды передается gauge ExecUp: 1, если последний
+// запуск завершился успешно, иначе 0.
+type Exec struct {
+	commands []*execCommand
+	now      func() time.Time
+}
+
+type execCommand struct {
+	config.ExecCommand
+	mu      sync.Mutex
+	lastRun time.Time
+	last    []models.Metrics
+	lastErr error
+}
+
+func NewExec(cfg *config.Config) *Exec {
+	e := &Exec{now: time.Now}
+	for _, command := range cfg.Exec {
+		if command.Timeout <= 0 {
+			command.Timeout = cfg.ExecTimeout
+		}
+		e.commands = append(e.commands, &execCommand{ExecCommand: command})
+	}
+	return e
+}
+
+func (e *Exec) Name() string { return "exec" }
+
+// Collect запускает команды параллельно. Команда с собственным интервалом
+// запускается, только если он истек, иначе используется предыдущий результат.
+func (e *Exec) Collect(ctx context.Context) ([]models.Metrics, error) {
+	results := make([][]models.Metrics, len(e.commands))
+	errs := make([]error, len(e.commands))
+
+	var wg sync.WaitGroup
+	for i, c := range e.commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = c.collect(ctx, e.now())
+		}()
+	}
+	wg.Wait()
+
+	var metrics []models.Metrics
+	for i, c := range e.commands {
+		status := 1.0
+		if errs[i] != nil {
+			status = 0
+			errs[i] = fmt.Errorf("command %s: %w", c.Name, errs[i])
+		}
+		for _, metric := range results[i] {
+			metrics = append(metrics, withCommand(metric, c.Name))
+		}
+		metrics = append(metrics, withCommand(gauge("ExecUp", status), c.Name))
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// withCommand возвращает метрику с меткой command. Метки метрики копируются:
+// результат команды хранится между запусками и не должен изменяться.
+// Метка command из вывода команды заменяется именем из конфигурации.
+func withCommand(metric models.Metrics, name string) models.Metrics {
+	labels := make(map[string]string, len(metric.Labels)+1)
+	for k, v := range metric.Labels {
+		labels[k] = v
+	}
+	labels[CommandLabel] = name
+	return withLabels(metric, labels)
+}
+
+func (c *execCommand) collect(ctx context.Context, now time.Time) ([]models.Metrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Interval > 0 && !c.lastRun.IsZero() && now.Sub(c.lastRun) < c.Interval {
+		return c.last, c.lastErr
+	}
+	c.lastRun = now
+	c.last, c.lastErr = c.run(ctx)
+	return c.last, c.lastErr
+}
+
+func (c *execCommand) run(ctx context.Context) ([]models.Metrics, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// Дочерние процессы команды могут держать вывод открытым после ее завершения
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("timed out after %v", c.Timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return parseExecOutput(output)
+}
+
+// parseExecOutput разбирает вывод команды. Вывод, начинающийся с '[',
+// считается JSON-массивом метрик, иначе разбирается построчно; пустые
+// строки и строки, начинающиеся с '#', пропускаются.
+func parseExecOutput(output []byte) ([]models.Metrics, error) {
+	output = bytes.TrimSpace(output)
+	if bytes.HasPrefix(output, []byte("[")) {
+		var metrics []models.Metrics
+		if err := json.Unmarshal(output, &metrics); err != nil {
+			return nil, fmt.Errorf("invalid JSON output: %w", err)
+		}
+		for _, metric := range metrics {
+			if err := validateExecMetric(metric); err != nil {
+				return nil, err
+			}
+		}
+		return metrics, nil
+	}
+
+	var metrics []models.Metrics
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected \"name type value\", got %q", n, line)
+		}
+		name, mType, value := fields[0], fields[1], fields[2]
+		switch mType {
+		case "gauge":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid gauge value %q", n, value)
+			}
+			metrics = append(metrics, gauge(name, v))
+		case "counter":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid counter value %q", n, value)
+			}
+			metrics = append(metrics, counter(name, v))
+		default:
+			return nil, fmt.Errorf("line %d: unknown metric type %q", n, mType)
+		}
+	}
+	return metrics, scanner.Err()
+}
+
+// validateExecMetric проверяет метрику из JSON-вывода. Гистограммы и сводки
+// не принимаются: агент хранит последнее значение коллектора и отправляет
+// его при каждой отправке, что для них означало бы повторный учет.
+func validateExecMetric(metric models.Metrics) error {
+	if metric.ID == "" {
+		return errors.New("metric without id")
+	}
+	switch metric.MType {
+	case "gauge":
+		if metric.Value == nil {
+			return fmt.Errorf("metric %s: missing value", metric.ID)
+		}
+	case "counter":
+		if metric.Delta == nil {
+			return fmt.Errorf("metric %s: missing delta", metric.ID)
+		}
+	default:
+		return fmt.Errorf("metric %s: unsupported type %q", metric.ID, metric.MType)
+	}
+	if err := models.ValidateLabels(metric.Labels); err != nil {
+		return fmt.Errorf("metric %s: %w", metric.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-metrics-server/internal/agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScript создает исполняемый shell-скрипт с телом body.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "check.sh")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755))
+	return path
+}
+
+func TestParseExecOutput(t *testing.T) {
+	t.Run("lines", func(t *testing.T) {
+		metrics, err := parseExecOutput([]byte("# queue\nQueueDepth gauge 12.5\n\nJobsDone counter 7\n"))
+		require.NoError(t, err)
+		require.Len(t, metrics, 2)
+		assert.Equal(t, 12.5, *metrics[0].Value)
+		assert.Equal(t, "counter", metrics[1].MType)
+		assert.Equal(t, int64(7), *metrics[1].Delta)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		metrics, err := parseExecOutput([]byte(`[{"id":"CertExpiryDays","type":"gauge","value":30,"labels":{"host":"example.com"}}]`))
+		require.NoError(t, err)
+		require.Len(t, metrics, 1)
+		assert.Equal(t, 30.0, *metrics[0].Value)
+		assert.Equal(t, "example.com", metrics[0].Labels["host"])
+	})
+
+	for name, output := range map[string]string{
+		"missing field":     "QueueDepth 12",
+		"unknown type":      "QueueDepth meter 12",
+		"invalid gauge":     "QueueDepth gauge abc",
+		"float counter":     "JobsDone counter 1.5",
+		"broken json":       `[{"id":`,
+		"json without id":   `[{"type":"gauge","value":1}]`,
+		"json no value":     `[{"id":"a","type":"gauge"}]`,
+		"json histogram":    `[{"id":"a","type":"histogram","histogram":{"bounds":[],"counts":[0]}}]`,
+		"json empty label":  `[{"id":"a","type":"gauge","value":1,"labels":{"":"x"}}]`,
+		"json unknown type": `[{"id":"a","type":"meter","value":1}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseExecOutput([]byte(output))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExec_Collect(t *testing.T) {
+	ok := writeScript(t, `echo "QueueDepth gauge 3"`)
+	failing := writeScript(t, `echo "boom" >&2; exit 2`)
+
+	e := NewExec(&config.Config{
+		ExecTimeout: time.Second,
+		Exec: []config.ExecCommand{
+			{Name: "queue", Command: []string{ok}},
+			{Name: "broken", Command: []string{failing}},
+		},
+	})
+
+	metrics, err := e.Collect(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "command broken")
+	assert.Contains(t, err.Error(), "boom")
+
+	byName := metricsByName(metrics)
+	require.Len(t, byName, 3)
+	assert.Equal(t, 3.0, *byName[`QueueDepth{command="queue"}`].Value)
+	assert.Equal(t, 1.0, *byName[`ExecUp{command="queue"}`].Value)
+	assert.Equal(t, 0.0, *byName[`ExecUp{command="broken"}`].Value)
+}
+
+func TestExec_CommandLabel(t *testing.T) {
+	// Обе команды передают метрику с одним именем
+	plain := writeScript(t, `echo "Depth gauge 1"`)
+	labeled := writeScript(t, `echo '[{"id":"Depth","type":"gauge","value":2,"labels":{"queue":"mail","command":"x"}}]'`)
+
+	e := NewExec(&config.Config{
+		ExecTimeout: time.Second,
+		Exec: []config.ExecCommand{
+			{Name: "plain", Command: []string{plain}},
+			{Name: "labeled", Command: []string{labeled}},
+		},
+	})
+	metrics, err := e.Collect(context.Background())
+	require.NoError(t, err)
+
+	byName := metricsByName(metrics)
+	assert.Equal(t, 1.0, *byName[`Depth{command="plain"}`].Value)
+	assert.Equal(t, 2.0, *byName[`Depth{command="labeled",queue="mail"}`].Value)
+
+	// Сохраненный результат команды не изменяется
+	assert.Equal(t, "x", e.commands[1].last[0].Labels[CommandLabel])
+	assert.Nil(t, e.commands[0].last[0].Labels)
+}
+
+func TestExec_Interval(t *testing.T) {
+	counterFile := filepath.Join(t.TempDir(), "runs")
+	script := writeScript(t, `echo x >> `+counterFile+`; echo "Runs gauge $(wc -l < `+counterFile+`)"`)
+
+	e := NewExec(&config.Config{
+		ExecTimeout: time.Second,
+		Exec:        []config.ExecCommand{{Name: "runs", Command: []string{script}, Interval: time.Minute}},
+	})
+	now := time.Unix(0, 0)
+	e.now = func() time.Time { return now }
+
+	collect := func() float64 {
+		metrics, err := e.Collect(context.Background())
+		require.NoError(t, err)
+		return *metricsByName(metrics)[`Runs{command="runs"}`].Value
+	}
+
+	assert.Equal(t, 1.0, collect())
+	// До истечения интервала используется прошлый результат
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, 1.0, collect())
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, 2.0, collect())
+}
+
+func TestExec_Timeout(t *testing.T) {
+	script := writeScript(t, `sleep 5`)
+	e := NewExec(&config.Config{
+		ExecTimeout: time.Second,
+		Exec:        []config.ExecCommand{{Name: "slow", Command: []string{script}, Timeout: 100 * time.Millisecond}},
+	})
+
+	start := time.Now()
+	metrics, err := e.Collect(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.Less(t, time.Since(start), 3*time.Second)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 0.0, *metrics[0].Value)
+}
@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// nameFilter отбирает имена (точки монтирования, интерфейсы) по шаблонам
+// filepath.Match. Пустой список include разрешает все имена, exclude
+// проверяется после include.
+type nameFilter struct {
+	include []string
+	exclude []string
+}
+
+func newNameFilter(include, exclude []string) (nameFilter, error) {
+	for _, pattern := range append(append([]string(nil), include...), exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nameFilter{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nameFilter{include: include, exclude: exclude}, nil
+}
+
+func (f nameFilter) match(name string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, name) {
+		return false
+	}
+	return !matchAny(f.exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
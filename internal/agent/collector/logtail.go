@@ -0,0 +1,236 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+func init() {
+	RegisterFactory("logtail", func(cfg *config.Config) (Collector, error) { return NewLogTail(cfg) })
+}
+
+// FileLabel — метка с путем к журналу.
+const FileLabel = "file"
+
+// maxLineLength ограничивает длину незавершенной строки журнала;
+// более длинная строка отбрасывается.
+const maxLineLength = 64 * 1024
+
+// LogTail дочитывает журналы при каждом опросе и считает строки, подходящие
+// под правила. Счетчики накопительные с момента запуска агента: содержимое,
+// записанное до запуска, не учитывается. Ротация переименованием
+// обнаруживается по смене файла по пути, при этом старый файл дочитывается;
+// усечение — по уменьшению размера, после него файл читается с начала.
+type LogTail struct {
+	mu    sync.Mutex
+	files []*tailedFile
+}
+
+type logRule struct {
+	name    string
+	gauge   string
+	re      *regexp.Regexp
+	group   int
+	matches int64
+	value   *float64 // Последнее извлеченное число
+}
+
+type tailedFile struct {
+	path    string
+	rules   []*logRule
+	file    *os.File
+	offset  int64
+	partial []byte // Строка без завершающего перевода строки
+	started bool   // Первый опрос выполнен: появившиеся позже файлы читаются с начала
+}
+
+func NewLogTail(cfg *config.Config) (*LogTail, error) {
+	l := &LogTail{}
+	for _, lf := range cfg.LogFiles {
+		f := &tailedFile{path: lf.Path}
+		for _, r := range lf.Rules {
+			rule, err := newLogRule(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", lf.Path, err)
+			}
+			f.rules = append(f.rules, rule)
+		}
+		l.files = append(l.files, f)
+	}
+	return l, nil
+}
+
+func newLogRule(r config.LogRule) (*logRule, error) {
+	if r.Name == "" {
+		return nil, errors.New("rule name is required")
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	rule := &logRule{name: r.Name, gauge: r.Gauge, re: re}
+	if r.Gauge == "" {
+		return rule, nil
+	}
+
+	rule.group = 1
+	if r.Group != "" {
+		if rule.group, err = strconv.Atoi(r.Group); err != nil {
+			rule.group = re.SubexpIndex(r.Group)
+		}
+	}
+	if rule.group < 1 || rule.group > re.NumSubexp() {
+		return nil, fmt.Errorf("rule %s: pattern has no group %q", r.Name, r.Group)
+	}
+	return rule, nil
+}
+
+func (l *LogTail) Name() string { return "logtail" }
+
+func (l *LogTail) Collect(ctx context.Context) ([]models.Metrics, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var metrics []models.Metrics
+	var errs []error
+	for _, f := range l.files {
+		if err := f.poll(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.path, err))
+		}
+		for _, r := range f.rules {
+			matches := counter(r.name, r.matches)
+			matches.Labels = map[string]string{FileLabel: f.path}
+			metrics = append(metrics, matches)
+			if r.value != nil {
+				value := gauge(r.gauge, *r.value)
+				value.Labels = map[string]string{FileLabel: f.path}
+				metrics = append(metrics, value)
+			}
+		}
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// poll дочитывает журнал, обрабатывая ротацию и усечение.
+func (f *tailedFile) poll() error {
+	info, err := os.Stat(f.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if f.file != nil {
+		current, err := f.file.Stat()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info == nil || !os.SameFile(info, current):
+			// Файл переименован или удален: дочитываем его и переходим к новому
+			readErr := f.read()
+			f.match(f.partial)
+			f.close()
+			if readErr != nil {
+				return readErr
+			}
+		case current.Size() < f.offset:
+			if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			f.offset = 0
+			f.partial = nil
+		}
+	}
+
+	if f.file == nil {
+		started := f.started
+		f.started = true
+		if info == nil {
+			return nil
+		}
+		file, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		f.file = file
+		if !started {
+			if f.offset, err = file.Seek(0, io.SeekEnd); err != nil {
+				f.close()
+				return err
+			}
+		}
+	}
+	return f.read()
+}
+
+// read читает файл до конца и обрабатывает завершенные строки.
+func (f *tailedFile) read() error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.file.Read(buf)
+		if n > 0 {
+			f.offset += int64(n)
+			f.consume(buf[:n])
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (f *tailedFile) consume(chunk []byte) {
+	data := append(f.partial, chunk...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		f.match(data[:i])
+		data = data[i+1:]
+	}
+	if len(data) > maxLineLength {
+		data = nil
+	}
+	f.partial = append([]byte(nil), data...)
+}
+
+func (f *tailedFile) match(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		return
+	}
+	for _, r := range f.rules {
+		if r.gauge == "" {
+			if r.re.Match(line) {
+				r.matches++
+			}
+			continue
+		}
+		groups := r.re.FindSubmatch(line)
+		if groups == nil {
+			continue
+		}
+		r.matches++
+		if v, err := strconv.ParseFloat(string(groups[r.group]), 64); err == nil {
+			r.value = &v
+		}
+	}
+}
+
+func (f *tailedFile) close() {
+	f.file.Close()
+	f.file = nil
+	f.offset = 0
+	f.partial = nil
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func newTestLogTail(t *testing.T, path string) *LogTail {
+	t.Helper()
+	l, err := NewLogTail(&config.Config{LogFiles: []config.LogFile{{
+		Path: path,
+		Rules: []config.LogRule{
+			{Name: "LogErrors", Pattern: `ERROR`},
+			{Name: "LogRequests", Pattern: `took (?P<ms>\d+)ms`, Gauge: "LogRequestTime", Group: "ms"},
+		},
+	}}})
+	require.NoError(t, err)
+	return l
+}
+
+// collectLog возвращает метрики коллектора по имени без меток.
+func collectLog(t *testing.T, l *LogTail) map[string]models.Metrics {
+	t.Helper()
+	metrics, err := l.Collect(context.Background())
+	require.NoError(t, err)
+	result := make(map[string]models.Metrics, len(metrics))
+	for _, m := range metrics {
+		result[m.ID] = m
+	}
+	return result
+}
+
+func TestNewLogTail_InvalidRules(t *testing.T) {
+	for name, rule := range map[string]config.LogRule{
+		"no name":       {Pattern: "x"},
+		"bad pattern":   {Name: "A", Pattern: "("},
+		"no group":      {Name: "A", Pattern: "x", Gauge: "B"},
+		"unknown group": {Name: "A", Pattern: `(?P<ms>\d+)`, Gauge: "B", Group: "sec"},
+		"group range":   {Name: "A", Pattern: `(\d+)`, Gauge: "B", Group: "2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewLogTail(&config.Config{LogFiles: []config.LogFile{{Path: "/var/log/app.log", Rules: []config.LogRule{rule}}}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLogTail_Collect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "ERROR before start\n")
+	l := newTestLogTail(t, path)
+
+	// Записи до запуска не учитываются, счетчики передаются с нуля
+	metrics := collectLog(t, l)
+	assert.Equal(t, int64(0), *metrics["LogErrors"].Delta)
+	assert.Equal(t, path, metrics["LogErrors"].Labels[FileLabel])
+	assert.NotContains(t, metrics, "LogRequestTime")
+
+	appendFile(t, path, "ERROR one\nrequest took 120ms\nINFO ok\nERROR two, took 5")
+	metrics = collectLog(t, l)
+	assert.Equal(t, int64(1), *metrics["LogErrors"].Delta)
+	assert.Equal(t, int64(1), *metrics["LogRequests"].Delta)
+	assert.Equal(t, 120.0, *metrics["LogRequestTime"].Value)
+
+	// Незавершенная строка учитывается, когда допишется
+	appendFile(t, path, "0ms\r\n")
+	metrics = collectLog(t, l)
+	assert.Equal(t, int64(2), *metrics["LogErrors"].Delta)
+	assert.Equal(t, int64(2), *metrics["LogRequests"].Delta)
+	assert.Equal(t, 50.0, *metrics["LogRequestTime"].Value)
+}
+
+func TestLogTail_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+	l := newTestLogTail(t, path)
+	collectLog(t, l)
+
+	// Строки, дописанные перед ротацией, дочитываются из старого файла
+	appendFile(t, path, "ERROR old\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path+".1", "ERROR late\n")
+	appendFile(t, path, "ERROR new\n")
+
+	metrics := collectLog(t, l)
+	assert.Equal(t, int64(3), *metrics["LogErrors"].Delta)
+
+	appendFile(t, path, "ERROR again\n")
+	assert.Equal(t, int64(4), *collectLog(t, l)["LogErrors"].Delta)
+}
+
+func TestLogTail_Truncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+	l := newTestLogTail(t, path)
+	collectLog(t, l)
+
+	appendFile(t, path, "ERROR one\nERROR two\n")
+	assert.Equal(t, int64(2), *collectLog(t, l)["LogErrors"].Delta)
+
+	require.NoError(t, os.Truncate(path, 0))
+	appendFile(t, path, "ERROR x\n")
+	assert.Equal(t, int64(3), *collectLog(t, l)["LogErrors"].Delta)
+}
+
+func TestLogTail_MissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	l := newTestLogTail(t, path)
+	assert.Equal(t, int64(0), *collectLog(t, l)["LogErrors"].Delta)
+
+	// Появившийся после запуска файл читается с начала
+	appendFile(t, path, "ERROR first\n")
+	assert.Equal(t, int64(1), *collectLog(t, l)["LogErrors"].Delta)
+
+	require.NoError(t, os.Remove(path))
+	assert.Equal(t, int64(1), *collectLog(t, l)["LogErrors"].Delta)
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+func init() {
+	RegisterFactory("network", func(cfg *config.Config) (Collector, error) { return NewNetwork(cfg) })
+}
+
+// InterfaceLabel — метка с именем сетевого интерфейса.
+const InterfaceLabel = "interface"
+
+// Network собирает счетчики байт, пакетов и ошибок сетевых интерфейсов
+// и их скорость между опросами. Интерфейсы отбираются шаблонами
+// InterfaceInclude и InterfaceExclude.
+type Network struct {
+	filter     nameFilter
+	ioCounters func(ctx context.Context) ([]net.IOCountersStat, error)
+	rates      *rateTracker
+	now        func() time.Time
+}
+
+func NewNetwork(cfg *config.Config) (*Network, error) {
+	filter, err := newNameFilter(cfg.InterfaceInclude, cfg.InterfaceExclude)
+	if err != nil {
+		return nil, err
+	}
+	return &Network{
+		filter: filter,
+		ioCounters: func(ctx context.Context) ([]net.IOCountersStat, error) {
+			return net.IOCountersWithContext(ctx, true)
+		},
+		rates: newRateTracker(),
+		now:   time.Now,
+	}, nil
+}
+
+func (n *Network) Name() string { return "network" }
+
+// CountersSinceBoot сообщает, что счетчики интерфейсов накоплены с загрузки системы.
+func (n *Network) CountersSinceBoot() bool { return true }
+
+func (n *Network) Collect(ctx context.Context) ([]models.Metrics, error) {
+	counters, err := n.ioCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := n.now()
+	var metrics []models.Metrics
+	for _, c := range counters {
+		if !n.filter.match(c.Name) {
+			continue
+		}
+		labels := map[string]string{InterfaceLabel: c.Name}
+		metrics = append(metrics, rateMetrics(n.rates, labels, now, []counterValue{
+			{"NetBytesSent", c.BytesSent},
+			{"NetBytesRecv", c.BytesRecv},
+			{"NetPacketsSent", c.PacketsSent},
+			{"NetPacketsRecv", c.PacketsRecv},
+			{"NetErrorsIn", c.Errin},
+			{"NetErrorsOut", c.Errout},
+		})...)
+	}
+	return metrics, nil
+}
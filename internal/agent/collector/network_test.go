@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-metrics-server/internal/agent/config"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetwork_Collect(t *testing.T) {
+	n, err := NewNetwork(&config.Config{InterfaceExclude: []string{"lo", "veth*"}})
+	require.NoError(t, err)
+
+	sent := uint64(100)
+	n.ioCounters = func(context.Context) ([]net.IOCountersStat, error) {
+		return []net.IOCountersStat{
+			{Name: "lo", BytesSent: 1},
+			{Name: "veth123", BytesSent: 1},
+			{Name: "eth0", BytesSent: sent, Errin: 2},
+		}, nil
+	}
+	now := time.Unix(0, 0)
+	n.now = func() time.Time { return now }
+
+	metrics, err := n.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, metrics, 6)
+	for _, m := range metrics {
+		assert.Equal(t, "eth0", m.Labels[InterfaceLabel])
+	}
+
+	sent = 400
+	now = now.Add(3 * time.Second)
+	metrics, err = n.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, metrics, 12)
+
+	byName := metricsByName(metrics)
+	assert.Equal(t, int64(400), *byName[`NetBytesSent{interface="eth0"}`].Delta)
+	assert.Equal(t, 100.0, *byName[`NetBytesSentPerSec{interface="eth0"}`].Value)
+	assert.Equal(t, int64(2), *byName[`NetErrorsIn{interface="eth0"}`].Delta)
+}
+
+func TestNetwork_InvalidPattern(t *testing.T) {
+	_, err := NewNetwork(&config.Config{InterfaceInclude: []string{"eth["}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,240 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+func init() {
+	RegisterFactory("process", func(cfg *config.Config) (Collector, error) { return NewProcess(cfg) })
+}
+
+// ProcessLabel — метка с именем группы отслеживаемых процессов.
+const ProcessLabel = "process"
+
+// Process собирает потребление ресурсов группами процессов, отобранных
+// по имени, командной строке или pid-файлу. Значения процессов группы
+// суммируются, поэтому смена PID при перезапуске не порождает новых рядов.
+// Если процессов группы нет, передаются нулевые значения, а не последние
+// известные.
+type Process struct {
+	matchers []processMatcher
+	scan     bool // Есть группы, для которых нужен обход всех процессов
+
+	mu  sync.Mutex
+	cpu map[processKey]cpuSample // Процессорное время с прошлого опроса
+	now func() time.Time
+}
+
+type processMatcher struct {
+	name    string
+	pattern *regexp.Regexp
+	cmdline *regexp.Regexp
+	pidfile string
+}
+
+// processKey отличает процесс от нового процесса с тем же PID.
+type processKey struct {
+	matcher int
+	pid     int32
+	created int64
+}
+
+type cpuSample struct {
+	seconds float64
+	at      time.Time
+}
+
+// processStats — суммарные значения группы процессов.
+type processStats struct {
+	count      int
+	cpuPercent float64
+	rss        uint64
+	fds        int64
+	threads    int64
+	oldest     int64 // Время запуска самого старого процесса, мс
+}
+
+func NewProcess(cfg *config.Config) (*Process, error) {
+	p := &Process{cpu: make(map[processKey]cpuSample), now: time.Now}
+	for _, m := range cfg.Processes {
+		matcher := processMatcher{name: m.Name, pidfile: m.Pidfile}
+		var err error
+		switch {
+		case m.Pattern != "":
+			matcher.pattern, err = regexp.Compile(m.Pattern)
+		case m.Cmdline != "":
+			matcher.cmdline, err = regexp.Compile(m.Cmdline)
+		case m.Pidfile == "":
+			err = errors.New("no pattern, cmdline or pidfile")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("process %s: %w", m.Name, err)
+		}
+		p.scan = p.scan || matcher.pidfile == ""
+		p.matchers = append(p.matchers, matcher)
+	}
+	return p, nil
+}
+
+func (p *Process) Name() string { return "process" }
+
+func (p *Process) Collect(ctx context.Context) ([]models.Metrics, error) {
+	matched, err := p.match(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	seen := make(map[processKey]bool)
+	var metrics []models.Metrics
+	for i, m := range p.matchers {
+		var stats processStats
+		for _, proc := range matched[i] {
+			p.add(ctx, &stats, processKey{matcher: i, pid: proc.Pid}, proc, now, seen)
+		}
+		metrics = append(metrics, stats.metrics(m.name, now)...)
+	}
+
+	// Завершившиеся процессы забываются
+	for key := range p.cpu {
+		if !seen[key] {
+			delete(p.cpu, key)
+		}
+	}
+	return metrics, nil
+}
+
+// match возвращает процессы каждой группы.
+func (p *Process) match(ctx context.Context) ([][]*process.Process, error) {
+	matched := make([][]*process.Process, len(p.matchers))
+
+	if p.scan {
+		procs, err := process.ProcessesWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list processes: %w", err)
+		}
+		for _, proc := range procs {
+			// Имя и командная строка читаются, только если нужны,
+			// ошибка означает, что процесс уже завершился
+			var name, cmdline *string
+			for i, m := range p.matchers {
+				switch {
+				case m.pattern != nil:
+					if name == nil {
+						n, _ := proc.NameWithContext(ctx)
+						name = &n
+					}
+					if *name != "" && m.pattern.MatchString(*name) {
+						matched[i] = append(matched[i], proc)
+					}
+				case m.cmdline != nil:
+					if cmdline == nil {
+						c, _ := proc.CmdlineWithContext(ctx)
+						cmdline = &c
+					}
+					if *cmdline != "" && m.cmdline.MatchString(*cmdline) {
+						matched[i] = append(matched[i], proc)
+					}
+				}
+			}
+		}
+	}
+
+	for i, m := range p.matchers {
+		if m.pidfile == "" {
+			continue
+		}
+		// Отсутствие pid-файла или процесса означает, что процесс не запущен
+		if proc := processFromPidfile(ctx, m.pidfile); proc != nil {
+			matched[i] = append(matched[i], proc)
+		}
+	}
+	return matched, nil
+}
+
+func processFromPidfile(ctx context.Context, name string) *process.Process {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return nil
+	}
+	proc, err := process.NewProcessWithContext(ctx, int32(pid))
+	if err != nil {
+		return nil
+	}
+	return proc
+}
+
+// add прибавляет значения процесса к stats. Процесс, завершившийся во время
+// опроса, пропускается; недоступные значения (например, число открытых
+// файлов чужого процесса) не учитываются.
+func (p *Process) add(ctx context.Context, stats *processStats, key processKey, proc *process.Process, now time.Time, seen map[processKey]bool) {
+	created, err := proc.CreateTimeWithContext(ctx)
+	if err != nil {
+		return
+	}
+	key.created = created
+	mem, err := proc.MemoryInfoWithContext(ctx)
+	if err != nil {
+		return
+	}
+
+	stats.count++
+	stats.rss += mem.RSS
+	if stats.oldest == 0 || created < stats.oldest {
+		stats.oldest = created
+	}
+	if fds, err := proc.NumFDsWithContext(ctx); err == nil {
+		stats.fds += int64(fds)
+	}
+	if threads, err := proc.NumThreadsWithContext(ctx); err == nil {
+		stats.threads += int64(threads)
+	}
+
+	times, err := proc.TimesWithContext(ctx)
+	if err != nil {
+		return
+	}
+	seen[key] = true
+	seconds := times.User + times.System
+	if prev, ok := p.cpu[key]; ok {
+		if elapsed := now.Sub(prev.at).Seconds(); elapsed > 0 && seconds >= prev.seconds {
+			stats.cpuPercent += (seconds - prev.seconds) / elapsed * 100
+		}
+	}
+	p.cpu[key] = cpuSample{seconds: seconds, at: now}
+}
+
+func (s processStats) metrics(name string, now time.Time) []models.Metrics {
+	var uptime float64
+	if s.oldest > 0 {
+		uptime = max(now.Sub(time.UnixMilli(s.oldest)).Seconds(), 0)
+	}
+	labels := map[string]string{ProcessLabel: name}
+	return []models.Metrics{
+		withLabels(gauge("ProcessCount", float64(s.count)), labels),
+		withLabels(gauge("ProcessCPUPercent", s.cpuPercent), labels),
+		withLabels(gauge("ProcessRSSBytes", float64(s.rss)), labels),
+		withLabels(gauge("ProcessOpenFDs", float64(s.fds)), labels),
+		withLabels(gauge("ProcessThreads", float64(s.threads)), labels),
+		withLabels(gauge("ProcessUptimeSeconds", uptime), labels),
+	}
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processValue(t *testing.T, metrics []models.Metrics, id, name string) float64 {
+	t.Helper()
+	m, ok := metricsByName(metrics)[models.SeriesName(id, map[string]string{ProcessLabel: name})]
+	require.True(t, ok, "metric %s for %s not found", id, name)
+	return *m.Value
+}
+
+func TestNewProcess_InvalidPattern(t *testing.T) {
+	_, err := NewProcess(&config.Config{Processes: []config.ProcessMatcher{{Name: "bad", Pattern: "("}}})
+	assert.Error(t, err)
+}
+
+func TestProcess_Pidfile(t *testing.T) {
+	dir := t.TempDir()
+	pidfile := filepath.Join(dir, "agent.pid")
+	require.NoError(t, os.WriteFile(pidfile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644))
+
+	p, err := NewProcess(&config.Config{Processes: []config.ProcessMatcher{
+		{Name: "self", Pidfile: pidfile},
+		{Name: "missing", Pidfile: filepath.Join(dir, "missing.pid")},
+	}})
+	require.NoError(t, err)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	metrics, err := p.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, metrics, 12)
+	assert.Equal(t, 1.0, processValue(t, metrics, "ProcessCount", "self"))
+	assert.Greater(t, processValue(t, metrics, "ProcessRSSBytes", "self"), 0.0)
+	assert.Greater(t, processValue(t, metrics, "ProcessThreads", "self"), 0.0)
+	assert.Greater(t, processValue(t, metrics, "ProcessOpenFDs", "self"), 0.0)
+	assert.GreaterOrEqual(t, processValue(t, metrics, "ProcessUptimeSeconds", "self"), 0.0)
+	assert.Equal(t, 0.0, processValue(t, metrics, "ProcessCount", "missing"))
+
+	// Загрузка процессора считается по приросту процессорного времени
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+	now = now.Add(time.Second)
+	metrics, err = p.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Greater(t, processValue(t, metrics, "ProcessCPUPercent", "self"), 0.0)
+}
+
+func TestProcess_Disappears(t *testing.T) {
+	cmd := exec.Command("sleep", "37.25")
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+
+	p, err := NewProcess(&config.Config{Processes: []config.ProcessMatcher{{Name: "sleeper", Cmdline: `^sleep 37\.25$`}}})
+	require.NoError(t, err)
+
+	metrics, err := p.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, processValue(t, metrics, "ProcessCount", "sleeper"))
+	assert.Greater(t, processValue(t, metrics, "ProcessRSSBytes", "sleeper"), 0.0)
+	assert.Len(t, p.cpu, 1)
+
+	require.NoError(t, cmd.Process.Kill())
+	cmd.Wait()
+
+	// Значения группы обнуляются, состояние процесса удаляется
+	metrics, err = p.Collect(context.Background())
+	require.NoError(t, err)
+	for _, id := range []string{"ProcessCount", "ProcessCPUPercent", "ProcessRSSBytes", "ProcessOpenFDs", "ProcessThreads", "ProcessUptimeSeconds"} {
+		assert.Equal(t, 0.0, processValue(t, metrics, id, "sleeper"), id)
+	}
+	assert.Empty(t, p.cpu)
+}
+
+func TestProcess_Pattern(t *testing.T) {
+	cmd := exec.Command("sleep", "37.5")
+	require.NoError(t, cmd.Start())
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	p, err := NewProcess(&config.Config{Processes: []config.ProcessMatcher{{Name: "sleep", Pattern: `^sleep$`}}})
+	require.NoError(t, err)
+	metrics, err := p.Collect(context.Background())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, processValue(t, metrics, "ProcessCount", "sleep"), 1.0)
+}
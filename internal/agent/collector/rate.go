@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+type rateSample struct {
+	value uint64
+	at    time.Time
+}
+
+// rateTracker вычисляет скорость изменения накопительных значений
+// между опросами.
+type rateTracker struct {
+	mu      sync.Mutex
+	samples map[string]rateSample
+}
+
+func newRateTracker() *rateTracker {
+	return &rateTracker{samples: make(map[string]rateSample)}
+}
+
+// rate запоминает значение и возвращает скорость в единицах в секунду
+// с момента предыдущего опроса. При первом опросе и после сброса значения
+// (например, при перезапуске интерфейса) скорость неизвестна и ok = false.
+func (t *rateTracker) rate(key string, value uint64, now time.Time) (rate float64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, seen := t.samples[key]
+	t.samples[key] = rateSample{value: value, at: now}
+
+	elapsed := now.Sub(prev.at).Seconds()
+	if !seen || value < prev.value || elapsed <= 0 {
+		return 0, false
+	}
+	return float64(value-prev.value) / elapsed, true
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+	"math/rand"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+func init() {
+	RegisterFactory("memory", func(*config.Config) (Collector, error) { return Memory{}, nil })
+	RegisterFactory("cpu", func(*config.Config) (Collector, error) { return CPU{}, nil })
+	RegisterFactory("random", func(*config.Config) (Collector, error) { return Random{}, nil })
+}
+
+// Memory собирает объем системной памяти.
+type Memory struct{}
+
+func (Memory) Name() string { return "memory" }
+
+func (Memory) Collect(ctx context.Context) ([]models.Metrics, error) {
+	v, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Metrics{
+		gauge("TotalMemory", float64(v.Total)),
+		gauge("FreeMemory", float64(v.Free)),
+	}, nil
+}
+
+// CPU собирает загрузку каждого процессора с момента предыдущего опроса.
+type CPU struct{}
+
+func (CPU) Name() string { return "cpu" }
+
+func (CPU) Collect(ctx context.Context) ([]models.Metrics, error) {
+	percents, err := cpu.PercentWithContext(ctx, 0, true)
+	if err != nil {
+		return nil, err
+	}
+	metrics := make([]models.Metrics, len(percents))
+	for i, percent := range percents {
+		metrics[i] = gauge(fmt.Sprintf("CPUutilization%d", i+1), percent)
+	}
+	return metrics, nil
+}
+
+// Random возвращает случайное значение RandomValue.
+type Random struct{}
+
+func (Random) Name() string { return "random" }
+
+func (Random) Collect(context.Context) ([]models.Metrics, error) {
+	return []models.Metrics{gauge("RandomValue", rand.Float64())}, nil
+}
@@ -1,41 +1,195 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"go-metrics-server/internal/configfile"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type Config struct {
-	ServerAddr     string        // Адрес сервера
-	PollInterval   time.Duration // Интервал опроса метрик
-	ReportInterval time.Duration // Интервал отправки метрик
-	Key            string        // Ключ для подписи данных
-	RateLimit      int           // Ограничение количества одновременных запросов
+	ServerAddr      string        // Адрес сервера или несколько адресов через запятую
+	Servers         []string      // Адреса серверов, разобранные из ServerAddr
+	ServerMode      string        // Режим работы с несколькими серверами: failover или fanout
+	PollInterval    time.Duration // Интервал опроса метрик
+	ReportInterval  time.Duration // Интервал отправки метрик
+	Key             string        // Ключ для подписи данных
+	RateLimit       int           // Ограничение количества одновременных отправок на все серверы
+	AgentID         string        // Идентификатор экземпляра агента
+	CryptoKey       string        // Путь к файлу с публичным ключом сервера
+	Transport       string        // Протокол отправки метрик: http или grpc
+	OutboxPath      string        // Каталог очереди неотправленных пакетов, пустая строка — очередь в памяти
+	OutboxSize      int           // Максимальное число пакетов в очереди
+	Collectors      []string      // Включенные коллекторы метрик
+	GaugeAggregates []string      // Агрегаты gauge за окно между отправками: last, min, max, mean, pNN
+	GaugeMode       string        // Способ отправки агрегатов: suffix или summary
+	ShutdownTimeout time.Duration // Время на отправку накопленных метрик при остановке
+	ListenAddr      string        // Адрес, на котором агент отдает свои метрики; пустая строка отключает режим
+	StatsDAddr      string        // UDP-адрес приема метрик StatsD; пустая строка отключает прием
+	StatsDTimers    string        // Представление таймеров StatsD: histogram или summary
+	ExecTimeout     time.Duration // Ограничение времени выполнения внешней команды по умолчанию
+
+	// Внешние команды коллектора exec. Если они заданы, коллектор
+	// включается автоматически. В EXEC_COMMANDS команды разделяются
+	// переводом строки, флаг -exec указывается для каждой команды;
+	// аргументы с пробелами и запятыми заключаются в кавычки
+	Exec []ExecCommand
+
+	// Корень файловой системы cgroup v2 и пути cgroup относительно него для
+	// коллектора cgroup; пустой список — cgroup самого агента. Если пути
+	// заданы, коллектор включается автоматически
+	CgroupRoot  string
+	CgroupPaths []string
+
+	// Отслеживаемые процессы коллектора process. Если они заданы,
+	// коллектор включается автоматически. В PROCESSES выражения разделяются
+	// переводом строки, флаг -processes указывается для каждого выражения
+	Processes []ProcessMatcher
+
+	// Отслеживаемые журналы коллектора logtail, задаются только в файле
+	// конфигурации. Если они заданы, коллектор включается автоматически
+	LogFiles []LogFile
+
+	// Шаблоны filepath.Match для отбора точек монтирования и сетевых
+	// интерфейсов; пустой список include разрешает все
+	MountInclude     []string
+	MountExclude     []string
+	InterfaceInclude []string
+	InterfaceExclude []string
+}
+
+// ExecCommand — внешняя команда, вывод которой агент разбирает как метрики.
+type ExecCommand struct {
+	Name     string        // Имя для метки command, по умолчанию — имя исполняемого файла (с номером при совпадении)
+	Command  []string      // Исполняемый файл и его аргументы
+	Interval time.Duration // Интервал запуска; 0 — при каждом опросе
+	Timeout  time.Duration // Ограничение времени выполнения; 0 — ExecTimeout
+}
+
+// ProcessMatcher описывает группу отслеживаемых процессов. Задается ровно
+// один способ отбора: Pattern, Cmdline или Pidfile.
+type ProcessMatcher struct {
+	Name    string // Значение метки process, по умолчанию — условие отбора
+	Pattern string // Регулярное выражение для имени процесса
+	Cmdline string // Регулярное выражение для командной строки
+	Pidfile string // Файл с PID процесса
+}
+
+// LogFile — журнал, строки которого проверяются правилами.
+type LogFile struct {
+	Path  string
+	Rules []LogRule
+}
+
+// LogRule — правило разбора строк журнала. Каждая подходящая строка
+// увеличивает счетчик Name; если задан Gauge, число из группы Group
+// передается как gauge.
+type LogRule struct {
+	Name    string // Имя счетчика совпадений
+	Pattern string // Регулярное выражение
+	Gauge   string // Имя gauge для числа из группы; пустая строка — не извлекать
+	Group   string // Номер или имя группы, по умолчанию 1
 }
 
 func NewConfig() *Config {
 	cfg := &Config{}
 
 	defaultServerAddr := "localhost:8080"
-	defaultPollInterval := 2
-	defaultReportInterval := 10
+	defaultPollInterval := 2 * time.Second
+	defaultReportInterval := 10 * time.Second
 	defaultKey := ""
 	defaultRateLimit := 1
+	defaultAgentID := generateAgentID()
+	defaultCryptoKey := ""
+	defaultTransport := "http"
+	defaultServerMode := "failover"
+	defaultOutboxPath := "/tmp/metrics-agent-outbox"
+	defaultOutboxSize := 100
+	defaultCollectors := "runtime,memory,cpu,random,filesystem,diskio,network"
+	defaultMountInclude := ""
+	defaultMountExclude := ""
+	defaultInterfaceInclude := ""
+	defaultInterfaceExclude := "lo"
+	defaultGaugeAggregates := "last"
+	defaultGaugeMode := "suffix"
+	defaultShutdownTimeout := 5 * time.Second
+	defaultListenAddr := ""
+	defaultStatsDAddr := ""
+	defaultStatsDTimers := "histogram"
+	defaultExecTimeout := 10 * time.Second
+	defaultExec := ""
+	defaultCgroupRoot := "/sys/fs/cgroup"
+	defaultCgroupPaths := ""
+	var fileExec []ExecCommand
+	var fileLogs []LogFile
+	defaultProcesses := ""
+	var fileProcesses []ProcessMatcher
+
+	args := filterArgs(os.Args[1:])
+
+	// Файл конфигурации переопределяет значения по умолчанию,
+	// переменные окружения и флаги — значения из файла
+	configPath := configfile.Path(args)
+	if configPath != "" {
+		var f fileConfig
+		if err := configfile.Load(configPath, &f); err != nil {
+			fmt.Println("Ошибка при чтении файла конфигурации:", err)
+			os.Exit(1)
+		}
+		setString(&defaultServerAddr, f.Address)
+		setDuration(&defaultPollInterval, f.PollInterval)
+		setDuration(&defaultReportInterval, f.ReportInterval)
+		setString(&defaultKey, f.Key)
+		if f.RateLimit != nil {
+			defaultRateLimit = *f.RateLimit
+		}
+		setString(&defaultAgentID, f.AgentID)
+		setString(&defaultCryptoKey, f.CryptoKey)
+		setString(&defaultTransport, f.Transport)
+		setList(&defaultServerAddr, f.Servers)
+		setString(&defaultServerMode, f.ServerMode)
+		setString(&defaultOutboxPath, f.OutboxPath)
+		if f.OutboxSize != nil {
+			defaultOutboxSize = *f.OutboxSize
+		}
+		setList(&defaultCollectors, f.Collectors)
+		setList(&defaultMountInclude, f.MountInclude)
+		setList(&defaultMountExclude, f.MountExclude)
+		setList(&defaultInterfaceInclude, f.InterfaceInclude)
+		setList(&defaultInterfaceExclude, f.InterfaceExclude)
+		setList(&defaultGaugeAggregates, f.GaugeAggregates)
+		setString(&defaultGaugeMode, f.GaugeMode)
+		setDuration(&defaultShutdownTimeout, f.ShutdownTimeout)
+		setString(&defaultListenAddr, f.ListenAddr)
+		setString(&defaultStatsDAddr, f.StatsDAddr)
+		setString(&defaultStatsDTimers, f.StatsDTimers)
+		setDuration(&defaultExecTimeout, f.ExecTimeout)
+		setString(&defaultCgroupRoot, f.CgroupRoot)
+		setList(&defaultCgroupPaths, f.CgroupPaths)
+		fileExec = f.execCommands()
+		fileLogs = f.logFiles()
+		fileProcesses = f.processMatchers()
+	}
 
 	if addr := os.Getenv("ADDRESS"); addr != "" {
 		defaultServerAddr = addr
 	}
 	if pollIntervalStr := os.Getenv("POLL_INTERVAL"); pollIntervalStr != "" {
-		if pollInterval, err := strconv.Atoi(pollIntervalStr); err == nil {
+		if pollInterval, err := parseSeconds(pollIntervalStr); err == nil {
 			defaultPollInterval = pollInterval
 		}
 	}
 	if reportIntervalStr := os.Getenv("REPORT_INTERVAL"); reportIntervalStr != "" {
-		if reportInterval, err := strconv.Atoi(reportIntervalStr); err == nil {
+		if reportInterval, err := parseSeconds(reportIntervalStr); err == nil {
 			defaultReportInterval = reportInterval
 		}
 	}
@@ -47,27 +201,402 @@ func NewConfig() *Config {
 			defaultRateLimit = rateLimit
 		}
 	}
+	if agentID := os.Getenv("AGENT_ID"); agentID != "" {
+		defaultAgentID = agentID
+	}
+	if cryptoKey := os.Getenv("CRYPTO_KEY"); cryptoKey != "" {
+		defaultCryptoKey = cryptoKey
+	}
+	if transport := os.Getenv("TRANSPORT"); transport != "" {
+		defaultTransport = transport
+	}
+	if serverMode := os.Getenv("SERVER_MODE"); serverMode != "" {
+		defaultServerMode = serverMode
+	}
+	if outboxPath, ok := os.LookupEnv("OUTBOX_PATH"); ok {
+		defaultOutboxPath = outboxPath
+	}
+	if outboxSizeStr := os.Getenv("OUTBOX_SIZE"); outboxSizeStr != "" {
+		if outboxSize, err := strconv.Atoi(outboxSizeStr); err == nil {
+			defaultOutboxSize = outboxSize
+		}
+	}
+	lookupEnv(&defaultCollectors, "COLLECTORS")
+	lookupEnv(&defaultMountInclude, "MOUNT_INCLUDE")
+	lookupEnv(&defaultMountExclude, "MOUNT_EXCLUDE")
+	lookupEnv(&defaultInterfaceInclude, "INTERFACE_INCLUDE")
+	lookupEnv(&defaultInterfaceExclude, "INTERFACE_EXCLUDE")
+	lookupEnv(&defaultGaugeAggregates, "GAUGE_AGGREGATES")
+	if gaugeMode := os.Getenv("GAUGE_MODE"); gaugeMode != "" {
+		defaultGaugeMode = gaugeMode
+	}
+	if listenAddr := os.Getenv("LISTEN_ADDRESS"); listenAddr != "" {
+		defaultListenAddr = listenAddr
+	}
+	if statsdAddr := os.Getenv("STATSD_ADDRESS"); statsdAddr != "" {
+		defaultStatsDAddr = statsdAddr
+	}
+	if statsdTimers := os.Getenv("STATSD_TIMERS"); statsdTimers != "" {
+		defaultStatsDTimers = statsdTimers
+	}
+	if execTimeoutStr := os.Getenv("EXEC_TIMEOUT"); execTimeoutStr != "" {
+		if execTimeout, err := parseSeconds(execTimeoutStr); err == nil {
+			defaultExecTimeout = execTimeout
+		}
+	}
+	lookupEnv(&defaultExec, "EXEC_COMMANDS")
+	if cgroupRoot := os.Getenv("CGROUP_ROOT"); cgroupRoot != "" {
+		defaultCgroupRoot = cgroupRoot
+	}
+	lookupEnv(&defaultCgroupPaths, "CGROUP_PATHS")
+	lookupEnv(&defaultProcesses, "PROCESSES")
+	if shutdownTimeoutStr := os.Getenv("SHUTDOWN_TIMEOUT"); shutdownTimeoutStr != "" {
+		if shutdownTimeout, err := parseSeconds(shutdownTimeoutStr); err == nil {
+			defaultShutdownTimeout = shutdownTimeout
+		}
+	}
 
 	fs := flag.NewFlagSet("config", flag.ContinueOnError)
-	fs.StringVar(&cfg.ServerAddr, "a", defaultServerAddr, "Адрес HTTP-сервера")
-	pollInterval := fs.Int("p", defaultPollInterval, "Интервал опроса метрик (в секундах)")
-	reportInterval := fs.Int("r", defaultReportInterval, "Интервал отправки метрик (в секундах)")
+	fs.StringVar(&cfg.ServerAddr, "a", defaultServerAddr, "Адрес HTTP-сервера или несколько адресов через запятую")
+	fs.StringVar(&cfg.ServerMode, "server-mode", defaultServerMode, "Режим работы с несколькими серверами: failover или fanout")
+	cfg.PollInterval = defaultPollInterval
+	fs.Var((*seconds)(&cfg.PollInterval), "p", "Интервал опроса метрик (в секундах или с единицами, например 500ms)")
+	cfg.ReportInterval = defaultReportInterval
+	fs.Var((*seconds)(&cfg.ReportInterval), "r", "Интервал отправки метрик (в секундах или с единицами, например 500ms)")
 	fs.StringVar(&cfg.Key, "k", defaultKey, "Ключ для подписи данных")
-	fs.IntVar(&cfg.RateLimit, "l", defaultRateLimit, "Ограничение количества одновременных запросов")
-
-	args := filterArgs(os.Args[1:])
+	fs.IntVar(&cfg.RateLimit, "l", defaultRateLimit, "Ограничение количества одновременных отправок на все серверы")
+	fs.StringVar(&cfg.AgentID, "id", defaultAgentID, "Идентификатор экземпляра агента")
+	fs.StringVar(&cfg.CryptoKey, "crypto-key", defaultCryptoKey, "Путь к файлу с публичным ключом сервера")
+	fs.StringVar(&cfg.Transport, "transport", defaultTransport, "Протокол отправки метрик: http или grpc")
+	fs.StringVar(&cfg.OutboxPath, "outbox", defaultOutboxPath, "Каталог очереди неотправленных пакетов")
+	fs.IntVar(&cfg.OutboxSize, "outbox-size", defaultOutboxSize, "Максимальное число пакетов в очереди")
+	collectors := fs.String("collectors", defaultCollectors, "Включенные коллекторы метрик через запятую")
+	mountInclude := fs.String("mount-include", defaultMountInclude, "Шаблоны отбираемых точек монтирования через запятую")
+	mountExclude := fs.String("mount-exclude", defaultMountExclude, "Шаблоны исключаемых точек монтирования через запятую")
+	interfaceInclude := fs.String("iface-include", defaultInterfaceInclude, "Шаблоны отбираемых сетевых интерфейсов через запятую")
+	interfaceExclude := fs.String("iface-exclude", defaultInterfaceExclude, "Шаблоны исключаемых сетевых интерфейсов через запятую")
+	gaugeAggregates := fs.String("gauge-aggregates", defaultGaugeAggregates, "Агрегаты gauge за окно отправки через запятую: last, min, max, mean, pNN")
+	fs.StringVar(&cfg.GaugeMode, "gauge-mode", defaultGaugeMode, "Способ отправки агрегатов gauge: suffix или summary")
+	fs.StringVar(&cfg.ListenAddr, "listen", defaultListenAddr, "Адрес, на котором агент отдает свои метрики (/metrics, /metrics.json)")
+	fs.StringVar(&cfg.StatsDAddr, "statsd", defaultStatsDAddr, "UDP-адрес приема метрик StatsD")
+	fs.StringVar(&cfg.StatsDTimers, "statsd-timers", defaultStatsDTimers, "Представление таймеров StatsD: histogram или summary")
+	execCommands := &lineList{items: splitLines(defaultExec)}
+	fs.Var(execCommands, "exec", "Внешняя команда с аргументами, вывод которой разбирается как метрики; флаг можно указать несколько раз")
+	cfg.ExecTimeout = defaultExecTimeout
+	fs.Var((*seconds)(&cfg.ExecTimeout), "exec-timeout", "Ограничение времени выполнения внешней команды (в секундах или с единицами)")
+	fs.StringVar(&cfg.CgroupRoot, "cgroup-root", defaultCgroupRoot, "Корень файловой системы cgroup v2")
+	cgroupPaths := fs.String("cgroups", defaultCgroupPaths, "Пути cgroup относительно корня через запятую; по умолчанию cgroup агента")
+	processes := &lineList{items: splitLines(defaultProcesses)}
+	fs.Var(processes, "processes", "Регулярное выражение имен отслеживаемых процессов; флаг можно указать несколько раз")
+	cfg.ShutdownTimeout = defaultShutdownTimeout
+	fs.Var((*seconds)(&cfg.ShutdownTimeout), "shutdown-timeout", "Время на отправку накопленных метрик при остановке (в секундах или с единицами)")
+	// Путь к файлу уже определен configfile.Path, флаги регистрируются для корректного разбора
+	fs.String("c", configPath, "Путь к файлу конфигурации (JSON или YAML)")
+	fs.String("config", configPath, "Путь к файлу конфигурации (JSON или YAML)")
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Println("Ошибка при парсинге флагов:", err)
 		os.Exit(1)
 	}
 
-	cfg.PollInterval = time.Duration(*pollInterval) * time.Second
-	cfg.ReportInterval = time.Duration(*reportInterval) * time.Second
+	if cfg.Transport != "http" && cfg.Transport != "grpc" {
+		fmt.Println("Ошибка: неизвестный протокол отправки метрик:", cfg.Transport)
+		os.Exit(1)
+	}
+	if cfg.Transport == "grpc" && cfg.CryptoKey != "" {
+		fmt.Println("Ошибка: шифрование не поддерживается протоколом grpc")
+		os.Exit(1)
+	}
+	if cfg.ServerMode != "failover" && cfg.ServerMode != "fanout" {
+		fmt.Println("Ошибка: неизвестный режим работы с серверами:", cfg.ServerMode)
+		os.Exit(1)
+	}
+	if cfg.StatsDTimers != "histogram" && cfg.StatsDTimers != "summary" {
+		fmt.Println("Ошибка: неизвестное представление таймеров StatsD:", cfg.StatsDTimers)
+		os.Exit(1)
+	}
+	cfg.Servers = splitList(cfg.ServerAddr)
+	if len(cfg.Servers) == 0 {
+		fmt.Println("Ошибка: не задан адрес сервера")
+		os.Exit(1)
+	}
+	// Нулевой интервал сделал бы таймер агента недопустимым (time.NewTicker
+	// паникует), а нулевое ограничение времени — любую операцию просроченной
+	for _, v := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"-p (POLL_INTERVAL)", cfg.PollInterval},
+		{"-r (REPORT_INTERVAL)", cfg.ReportInterval},
+		{"-shutdown-timeout (SHUTDOWN_TIMEOUT)", cfg.ShutdownTimeout},
+		{"-exec-timeout (EXEC_TIMEOUT)", cfg.ExecTimeout},
+	} {
+		if v.value <= 0 {
+			fmt.Printf("Ошибка: интервал %s должен быть больше нуля, задано %s\n", v.name, v.value)
+			os.Exit(1)
+		}
+	}
+
+	cfg.Collectors = splitList(*collectors)
+	cfg.MountInclude = splitList(*mountInclude)
+	cfg.MountExclude = splitList(*mountExclude)
+	cfg.InterfaceInclude = splitList(*interfaceInclude)
+	cfg.InterfaceExclude = splitList(*interfaceExclude)
+	cfg.GaugeAggregates = splitList(*gaugeAggregates)
+
+	// Команды из окружения или флага заменяют команды из файла
+	cfg.Exec = fileExec
+	if len(execCommands.items) > 0 {
+		cfg.Exec = nil
+		for _, line := range execCommands.items {
+			command, err := splitCommand(line)
+			if err != nil {
+				fmt.Printf("Ошибка: неверная внешняя команда %q: %v\n", line, err)
+				os.Exit(1)
+			}
+			cfg.Exec = append(cfg.Exec, ExecCommand{Command: command})
+		}
+	}
+	if err := nameExecCommands(cfg.Exec); err != nil {
+		fmt.Println("Ошибка:", err)
+		os.Exit(1)
+	}
+	if len(cfg.Exec) > 0 && !slices.Contains(cfg.Collectors, "exec") {
+		cfg.Collectors = append(cfg.Collectors, "exec")
+	}
+
+	cfg.CgroupPaths = splitList(*cgroupPaths)
+	if len(cfg.CgroupPaths) > 0 && !slices.Contains(cfg.Collectors, "cgroup") {
+		cfg.Collectors = append(cfg.Collectors, "cgroup")
+	}
+
+	// Процессы из окружения или флага заменяют процессы из файла
+	cfg.Processes = fileProcesses
+	if len(processes.items) > 0 {
+		cfg.Processes = nil
+		for _, pattern := range processes.items {
+			cfg.Processes = append(cfg.Processes, ProcessMatcher{Pattern: pattern})
+		}
+	}
+	for i := range cfg.Processes {
+		m := &cfg.Processes[i]
+		selectors := 0
+		for _, selector := range []string{m.Pattern, m.Cmdline, m.Pidfile} {
+			if selector != "" {
+				selectors++
+				if m.Name == "" {
+					m.Name = selector
+				}
+			}
+		}
+		if selectors != 1 {
+			fmt.Println("Ошибка: для процесса должен быть задан ровно один из pattern, cmdline или pidfile:", m.Name)
+			os.Exit(1)
+		}
+	}
+	if len(cfg.Processes) > 0 && !slices.Contains(cfg.Collectors, "process") {
+		cfg.Collectors = append(cfg.Collectors, "process")
+	}
+
+	cfg.LogFiles = fileLogs
+	for _, logFile := range cfg.LogFiles {
+		if logFile.Path == "" || len(logFile.Rules) == 0 {
+			fmt.Println("Ошибка: для журнала должны быть заданы путь и правила:", logFile.Path)
+			os.Exit(1)
+		}
+	}
+	if len(cfg.LogFiles) > 0 && !slices.Contains(cfg.Collectors, "logtail") {
+		cfg.Collectors = append(cfg.Collectors, "logtail")
+	}
 
 	return cfg
 }
 
+// generateAgentID возвращает идентификатор агента по умолчанию: имя хоста,
+// а если его не удалось получить — случайную строку.
+func generateAgentID() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "agent"
+	}
+	return "agent-" + hex.EncodeToString(b)
+}
+
+// lookupEnv переносит значение переменной окружения name в dst, если она задана.
+// Заданная пустая переменная очищает список.
+func lookupEnv(dst *string, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*dst = value
+	}
+}
+
+// seconds — интервал во флаге. Число без единиц означает секунды,
+// как и раньше, а строка в формате time.ParseDuration позволяет задать
+// интервал точнее секунды.
+type seconds time.Duration
+
+func (s *seconds) String() string {
+	if s == nil {
+		return ""
+	}
+	d := time.Duration(*s)
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10)
+	}
+	return d.String()
+}
+
+func (s *seconds) Set(value string) error {
+	d, err := parseSeconds(value)
+	if err != nil {
+		return err
+	}
+	*s = seconds(d)
+	return nil
+}
+
+// parseSeconds разбирает интервал из флага или переменной окружения:
+// целое число секунд или строку в формате time.ParseDuration.
+func parseSeconds(value string) (time.Duration, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+// splitList разбирает список значений через запятую, пропуская пустые.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// lineList — список значений во флаге, в которых может встречаться запятая:
+// регулярные выражения (например, \d{2,3}) и командные строки. Разделителем
+// служит перевод строки, а флаг можно указать несколько раз. Значения флага
+// заменяют значения из окружения.
+type lineList struct {
+	items []string
+	set   bool
+}
+
+func (l *lineList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(l.items, "\n")
+}
+
+func (l *lineList) Set(value string) error {
+	if !l.set {
+		l.items, l.set = nil, true
+	}
+	l.items = append(l.items, splitLines(value)...)
+	return nil
+}
+
+// splitLines разбивает значение по строкам, пропуская пустые.
+func splitLines(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, "\n") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitCommand разбивает командную строку на аргументы по пробелам.
+// Одинарные и двойные кавычки объединяют аргумент с пробелами и запятыми,
+// обратная косая черта экранирует следующий символ вне одинарных кавычек.
+// Оболочка не запускается: подстановки и перенаправления не выполняются.
+func splitCommand(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// nameExecCommands проверяет команды и задает имена тем, у которых их нет.
+// Имя по умолчанию — имя исполняемого файла; если оно уже занято, к нему
+// добавляется номер (sh, sh-2), чтобы метрики разных команд не смешивались.
+// Повторение явно заданного имени — ошибка.
+func nameExecCommands(commands []ExecCommand) error {
+	names := make(map[string]bool, len(commands))
+	for _, command := range commands {
+		if len(command.Command) == 0 {
+			return fmt.Errorf("не задана внешняя команда %s", command.Name)
+		}
+		if command.Name == "" {
+			continue
+		}
+		if names[command.Name] {
+			return fmt.Errorf("имя внешней команды %s задано несколько раз", command.Name)
+		}
+		names[command.Name] = true
+	}
+	for i := range commands {
+		if commands[i].Name != "" {
+			continue
+		}
+		base := filepath.Base(commands[i].Command[0])
+		name := base
+		for n := 2; names[name]; n++ {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		commands[i].Name = name
+		names[name] = true
+	}
+	return nil
+}
+
 func filterArgs(args []string) []string {
 	var filtered []string
 	for i := 0; i < len(args); i++ {
@@ -2,10 +2,14 @@ package config
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -43,3 +47,413 @@ func TestNewConfig(t *testing.T) {
 	assert.Equal(t, 5*time.Second, cfg.PollInterval)
 	assert.Equal(t, 15*time.Second, cfg.ReportInterval)
 }
+
+func TestNewConfig_AgentID(t *testing.T) {
+	oldID := os.Getenv("AGENT_ID")
+	oldArgs := os.Args
+	defer func() {
+		os.Setenv("AGENT_ID", oldID)
+		os.Args = oldArgs
+	}()
+
+	os.Unsetenv("AGENT_ID")
+	os.Args = []string{"cmd"}
+	cfg := NewConfig()
+	assert.NotEmpty(t, cfg.AgentID)
+
+	os.Setenv("AGENT_ID", "env-agent")
+	cfg = NewConfig()
+	assert.Equal(t, "env-agent", cfg.AgentID)
+
+	os.Args = []string{"cmd", "-id=flag-agent"}
+	cfg = NewConfig()
+	assert.Equal(t, "flag-agent", cfg.AgentID)
+}
+
+func TestNewConfig_File(t *testing.T) {
+	for _, name := range []string{"ADDRESS", "POLL_INTERVAL", "REPORT_INTERVAL", "RATE_LIMIT", "CONFIG"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	path := filepath.Join(t.TempDir(), "agent.json")
+	content := `{"address":"10.0.0.1:8080","poll_interval":"5s","report_interval":20,"rate_limit":4,"agent_id":"file-agent"}`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	os.Args = []string{"cmd", "-config=" + path}
+	cfg := NewConfig()
+	assert.Equal(t, "10.0.0.1:8080", cfg.ServerAddr)
+	assert.Equal(t, 5*time.Second, cfg.PollInterval)
+	assert.Equal(t, 20*time.Second, cfg.ReportInterval)
+	assert.Equal(t, 4, cfg.RateLimit)
+	assert.Equal(t, "file-agent", cfg.AgentID)
+
+	// Окружение приоритетнее файла, флаги — приоритетнее окружения
+	os.Setenv("POLL_INTERVAL", "7")
+	os.Setenv("RATE_LIMIT", "2")
+	os.Args = []string{"cmd", "-c", path, "-l=3"}
+	cfg = NewConfig()
+	assert.Equal(t, 7*time.Second, cfg.PollInterval)
+	assert.Equal(t, 3, cfg.RateLimit)
+	assert.Equal(t, 20*time.Second, cfg.ReportInterval)
+}
+
+func TestNewConfig_SubSecondIntervals(t *testing.T) {
+	for _, name := range []string{"POLL_INTERVAL", "REPORT_INTERVAL", "SHUTDOWN_TIMEOUT", "EXEC_TIMEOUT", "CONFIG"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	// Доли секунды из файла не отбрасываются
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	content := "poll_interval: 500ms\nreport_interval: 1.5\nshutdown_timeout: 2500ms\nexec_timeout: 250ms\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	os.Args = []string{"cmd", "-c", path}
+	cfg := NewConfig()
+	assert.Equal(t, 500*time.Millisecond, cfg.PollInterval)
+	assert.Equal(t, 1500*time.Millisecond, cfg.ReportInterval)
+	assert.Equal(t, 2500*time.Millisecond, cfg.ShutdownTimeout)
+	assert.Equal(t, 250*time.Millisecond, cfg.ExecTimeout)
+
+	// В окружении и флагах число по-прежнему означает секунды
+	os.Setenv("POLL_INTERVAL", "100ms")
+	os.Args = []string{"cmd", "-r=3", "-shutdown-timeout=750ms"}
+	cfg = NewConfig()
+	assert.Equal(t, 100*time.Millisecond, cfg.PollInterval)
+	assert.Equal(t, 3*time.Second, cfg.ReportInterval)
+	assert.Equal(t, 750*time.Millisecond, cfg.ShutdownTimeout)
+}
+
+func TestNewConfig_Collectors(t *testing.T) {
+	t.Setenv("COLLECTORS", "")
+	os.Unsetenv("COLLECTORS")
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd"}
+	cfg := NewConfig()
+	assert.Equal(t, []string{"runtime", "memory", "cpu", "random", "filesystem", "diskio", "network"}, cfg.Collectors)
+
+	os.Setenv("COLLECTORS", "runtime, cpu")
+	cfg = NewConfig()
+	assert.Equal(t, []string{"runtime", "cpu"}, cfg.Collectors)
+
+	// Пустой список отключает все коллекторы
+	os.Args = []string{"cmd", "-collectors="}
+	cfg = NewConfig()
+	assert.Empty(t, cfg.Collectors)
+}
+
+func TestNewConfig_Patterns(t *testing.T) {
+	for _, name := range []string{"MOUNT_INCLUDE", "MOUNT_EXCLUDE", "INTERFACE_INCLUDE", "INTERFACE_EXCLUDE", "CONFIG"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd"}
+	cfg := NewConfig()
+	assert.Empty(t, cfg.MountInclude)
+	assert.Equal(t, []string{"lo"}, cfg.InterfaceExclude)
+
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	content := "mount_include: [\"/\", \"/data*\"]\ninterface_exclude: []\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	os.Setenv("INTERFACE_INCLUDE", "eth*")
+	os.Args = []string{"cmd", "-c", path, "-mount-exclude=/data/tmp"}
+	cfg = NewConfig()
+	assert.Equal(t, []string{"/", "/data*"}, cfg.MountInclude)
+	assert.Equal(t, []string{"/data/tmp"}, cfg.MountExclude)
+	assert.Equal(t, []string{"eth*"}, cfg.InterfaceInclude)
+	assert.Empty(t, cfg.InterfaceExclude)
+}
+
+func TestNewConfig_GaugeAggregates(t *testing.T) {
+	for _, name := range []string{"GAUGE_AGGREGATES", "GAUGE_MODE"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd"}
+	cfg := NewConfig()
+	assert.Equal(t, []string{"last"}, cfg.GaugeAggregates)
+	assert.Equal(t, "suffix", cfg.GaugeMode)
+
+	os.Setenv("GAUGE_AGGREGATES", "last,max,p99")
+	os.Args = []string{"cmd", "-gauge-mode=summary"}
+	cfg = NewConfig()
+	assert.Equal(t, []string{"last", "max", "p99"}, cfg.GaugeAggregates)
+	assert.Equal(t, "summary", cfg.GaugeMode)
+}
+
+func TestNewConfig_Servers(t *testing.T) {
+	for _, name := range []string{"ADDRESS", "SERVER_MODE", "CONFIG"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd"}
+	cfg := NewConfig()
+	assert.Equal(t, []string{"localhost:8080"}, cfg.Servers)
+	assert.Equal(t, "failover", cfg.ServerMode)
+
+	os.Args = []string{"cmd", "-a=primary:8080, dr:8080", "-server-mode=fanout"}
+	cfg = NewConfig()
+	assert.Equal(t, []string{"primary:8080", "dr:8080"}, cfg.Servers)
+	assert.Equal(t, "fanout", cfg.ServerMode)
+
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("servers: [a:1, b:2]\nserver_mode: fanout\n"), 0o644))
+	os.Args = []string{"cmd", "-c", path}
+	cfg = NewConfig()
+	assert.Equal(t, []string{"a:1", "b:2"}, cfg.Servers)
+	assert.Equal(t, "fanout", cfg.ServerMode)
+}
+
+func TestNewConfig_ListenAddr(t *testing.T) {
+	t.Setenv("LISTEN_ADDRESS", "")
+	os.Unsetenv("LISTEN_ADDRESS")
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd"}
+	assert.Empty(t, NewConfig().ListenAddr)
+
+	os.Setenv("LISTEN_ADDRESS", ":9100")
+	assert.Equal(t, ":9100", NewConfig().ListenAddr)
+
+	os.Args = []string{"cmd", "-listen=127.0.0.1:9200"}
+	assert.Equal(t, "127.0.0.1:9200", NewConfig().ListenAddr)
+}
+
+func TestNewConfig_StatsD(t *testing.T) {
+	for _, name := range []string{"STATSD_ADDRESS", "STATSD_TIMERS"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd"}
+	cfg := NewConfig()
+	assert.Empty(t, cfg.StatsDAddr)
+	assert.Equal(t, "histogram", cfg.StatsDTimers)
+
+	os.Setenv("STATSD_ADDRESS", ":8125")
+	os.Setenv("STATSD_TIMERS", "summary")
+	cfg = NewConfig()
+	assert.Equal(t, ":8125", cfg.StatsDAddr)
+	assert.Equal(t, "summary", cfg.StatsDTimers)
+
+	os.Args = []string{"cmd", "-statsd=127.0.0.1:9125", "-statsd-timers=histogram"}
+	cfg = NewConfig()
+	assert.Equal(t, "127.0.0.1:9125", cfg.StatsDAddr)
+	assert.Equal(t, "histogram", cfg.StatsDTimers)
+}
+
+func TestNewConfig_Exec(t *testing.T) {
+	for _, name := range []string{"EXEC_COMMANDS", "EXEC_TIMEOUT", "COLLECTORS", "CONFIG"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd"}
+	cfg := NewConfig()
+	assert.Empty(t, cfg.Exec)
+	assert.Equal(t, 10*time.Second, cfg.ExecTimeout)
+	assert.NotContains(t, cfg.Collectors, "exec")
+
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	content := "exec_timeout: 3s\nexec:\n  - name: queue\n    command: [/usr/local/bin/queue-depth, --json]\n    interval: 1m\n  - command: [/usr/local/bin/cert-expiry]\n    timeout: 20s\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	os.Args = []string{"cmd", "-c", path}
+	cfg = NewConfig()
+	assert.Equal(t, 3*time.Second, cfg.ExecTimeout)
+	assert.Equal(t, []ExecCommand{
+		{Name: "queue", Command: []string{"/usr/local/bin/queue-depth", "--json"}, Interval: time.Minute},
+		{Name: "cert-expiry", Command: []string{"/usr/local/bin/cert-expiry"}, Timeout: 20 * time.Second},
+	}, cfg.Exec)
+	assert.Contains(t, cfg.Collectors, "exec")
+
+	// Команды из окружения и флагов заменяют команды из файла
+	os.Setenv("EXEC_COMMANDS", "/opt/check-a --fast\n/opt/check-b")
+	os.Args = []string{"cmd", "-c", path, "-exec-timeout=5"}
+	cfg = NewConfig()
+	assert.Equal(t, 5*time.Second, cfg.ExecTimeout)
+	assert.Equal(t, []ExecCommand{
+		{Name: "check-a", Command: []string{"/opt/check-a", "--fast"}},
+		{Name: "check-b", Command: []string{"/opt/check-b"}},
+	}, cfg.Exec)
+
+	// Запятые и пробелы в кавычках остаются внутри аргумента,
+	// одинаковые имена исполняемых файлов получают номер
+	os.Args = []string{"cmd", "-exec=sh -c 'echo a, b'", "-exec", `sh -c "echo c"`, "-collectors=runtime,exec"}
+	cfg = NewConfig()
+	assert.Equal(t, []ExecCommand{
+		{Name: "sh", Command: []string{"sh", "-c", "echo a, b"}},
+		{Name: "sh-2", Command: []string{"sh", "-c", "echo c"}},
+	}, cfg.Exec)
+	assert.Equal(t, []string{"runtime", "exec"}, cfg.Collectors)
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"/opt/check --fast", []string{"/opt/check", "--fast"}},
+		{"  sh  -c 'a, b'  ", []string{"sh", "-c", "a, b"}},
+		{`"/opt/my checks/run" --name="x y"`, []string{"/opt/my checks/run", "--name=x y"}},
+		{`echo a\ b 'c\d' ""`, []string{"echo", "a b", `c\d`, ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.line)
+		assert.NoError(t, err, tt.line)
+		assert.Equal(t, tt.want, got, tt.line)
+	}
+
+	for _, line := range []string{"sh -c 'echo", `echo "a`, `echo a\`} {
+		_, err := splitCommand(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestNameExecCommands(t *testing.T) {
+	commands := []ExecCommand{
+		{Command: []string{"/bin/sh", "-c", "a"}},
+		{Name: "sh-2", Command: []string{"/opt/other"}},
+		{Command: []string{"sh", "-c", "b"}},
+		{Command: []string{"/opt/check"}},
+	}
+	require.NoError(t, nameExecCommands(commands))
+	assert.Equal(t, "sh", commands[0].Name)
+	assert.Equal(t, "sh-2", commands[1].Name)
+	assert.Equal(t, "sh-3", commands[2].Name)
+	assert.Equal(t, "check", commands[3].Name)
+
+	assert.Error(t, nameExecCommands([]ExecCommand{
+		{Name: "queue", Command: []string{"/opt/a"}},
+		{Name: "queue", Command: []string{"/opt/b"}},
+	}))
+	assert.Error(t, nameExecCommands([]ExecCommand{{Name: "empty"}}))
+}
+
+func TestNewConfig_LogFiles(t *testing.T) {
+	for _, name := range []string{"COLLECTORS", "CONFIG"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	path := filepath.Join(t.TempDir(), "agent.json")
+	content := `{"log_files":[{"path":"/var/log/app.log","rules":[
+		{"name":"AppErrors","pattern":"ERROR"},
+		{"name":"AppRequests","pattern":"took (\\d+)ms","gauge":"AppRequestTime","group":1}]}]}`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	os.Args = []string{"cmd", "-c", path}
+	cfg := NewConfig()
+	assert.Equal(t, []LogFile{{
+		Path: "/var/log/app.log",
+		Rules: []LogRule{
+			{Name: "AppErrors", Pattern: "ERROR"},
+			{Name: "AppRequests", Pattern: `took (\d+)ms`, Gauge: "AppRequestTime", Group: "1"},
+		},
+	}}, cfg.LogFiles)
+	assert.Contains(t, cfg.Collectors, "logtail")
+}
+
+func TestNewConfig_Cgroup(t *testing.T) {
+	for _, name := range []string{"CGROUP_ROOT", "CGROUP_PATHS", "COLLECTORS", "CONFIG"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"cmd"}
+	cfg := NewConfig()
+	assert.Equal(t, "/sys/fs/cgroup", cfg.CgroupRoot)
+	assert.Empty(t, cfg.CgroupPaths)
+	assert.NotContains(t, cfg.Collectors, "cgroup")
+
+	os.Setenv("CGROUP_PATHS", "/system.slice/app.service, /batch")
+	cfg = NewConfig()
+	assert.Equal(t, []string{"/system.slice/app.service", "/batch"}, cfg.CgroupPaths)
+	assert.Contains(t, cfg.Collectors, "cgroup")
+
+	os.Args = []string{"cmd", "-cgroup-root=/host/cgroup", "-cgroups="}
+	cfg = NewConfig()
+	assert.Equal(t, "/host/cgroup", cfg.CgroupRoot)
+	assert.Empty(t, cfg.CgroupPaths)
+}
+
+func TestNewConfig_Processes(t *testing.T) {
+	for _, name := range []string{"PROCESSES", "COLLECTORS", "CONFIG"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	content := "processes:\n  - name: web\n    pattern: ^nginx$\n  - cmdline: java .*kafka\n  - pidfile: /run/redis.pid\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	os.Args = []string{"cmd", "-c", path}
+	cfg := NewConfig()
+	assert.Equal(t, []ProcessMatcher{
+		{Name: "web", Pattern: "^nginx$"},
+		{Name: "java .*kafka", Cmdline: "java .*kafka"},
+		{Name: "/run/redis.pid", Pidfile: "/run/redis.pid"},
+	}, cfg.Processes)
+	assert.Contains(t, cfg.Collectors, "process")
+
+	// Процессы из окружения заменяют процессы из файла, выражения
+	// разделяются переводом строки и могут содержать запятые
+	os.Setenv("PROCESSES", "^postgres$\n^worker-\\d{1,3}$\n")
+	cfg = NewConfig()
+	assert.Equal(t, []ProcessMatcher{
+		{Name: "^postgres$", Pattern: "^postgres$"},
+		{Name: `^worker-\d{1,3}$`, Pattern: `^worker-\d{1,3}$`},
+	}, cfg.Processes)
+
+	// Повторенный флаг заменяет окружение
+	os.Args = []string{"cmd", "-processes", "^(nginx|envoy)$", "-processes", "^app-[a-z]{2,8}$"}
+	cfg = NewConfig()
+	assert.Equal(t, []ProcessMatcher{
+		{Name: "^(nginx|envoy)$", Pattern: "^(nginx|envoy)$"},
+		{Name: "^app-[a-z]{2,8}$", Pattern: "^app-[a-z]{2,8}$"},
+	}, cfg.Processes)
+}
+
+func TestNewConfig_NonPositiveIntervals(t *testing.T) {
+	// NewConfig завершает процесс, поэтому проверка идет в отдельном процессе
+	if args := os.Getenv("AGENT_CONFIG_ARGS"); args != "" {
+		os.Args = append([]string{"cmd"}, strings.Fields(args)...)
+		NewConfig()
+		return
+	}
+
+	for _, args := range []string{"-p=0", "-r=-1", "-shutdown-timeout=0s", "-exec-timeout=0"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestNewConfig_NonPositiveIntervals$")
+		cmd.Env = append(os.Environ(), "AGENT_CONFIG_ARGS="+args)
+		output, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		assert.ErrorAs(t, err, &exitErr, args)
+		assert.Contains(t, string(output), "должен быть больше нуля", args)
+	}
+}
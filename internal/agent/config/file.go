@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"go-metrics-server/internal/configfile"
+	"strings"
+	"time"
+)
+
+// fileConfig описывает файл конфигурации агента. Незаданные в файле
+// поля остаются nil и не переопределяют значения по умолчанию.
+type fileConfig struct {
+	Address        *string              `json:"address" yaml:"address"`
+	PollInterval   *configfile.Duration `json:"poll_interval" yaml:"poll_interval"`
+	ReportInterval *configfile.Duration `json:"report_interval" yaml:"report_interval"`
+	Key            *string              `json:"key" yaml:"key"`
+	RateLimit      *int                 `json:"rate_limit" yaml:"rate_limit"`
+	AgentID        *string              `json:"agent_id" yaml:"agent_id"`
+	CryptoKey      *string              `json:"crypto_key" yaml:"crypto_key"`
+	Transport      *string              `json:"transport" yaml:"transport"`
+	Servers        *[]string            `json:"servers" yaml:"servers"`
+	ServerMode     *string              `json:"server_mode" yaml:"server_mode"`
+	OutboxPath     *string              `json:"outbox_path" yaml:"outbox_path"`
+	OutboxSize     *int                 `json:"outbox_size" yaml:"outbox_size"`
+	Collectors     *[]string            `json:"collectors" yaml:"collectors"`
+
+	MountInclude     *[]string `json:"mount_include" yaml:"mount_include"`
+	MountExclude     *[]string `json:"mount_exclude" yaml:"mount_exclude"`
+	InterfaceInclude *[]string `json:"interface_include" yaml:"interface_include"`
+	InterfaceExclude *[]string `json:"interface_exclude" yaml:"interface_exclude"`
+
+	GaugeAggregates *[]string `json:"gauge_aggregates" yaml:"gauge_aggregates"`
+	GaugeMode       *string   `json:"gauge_mode" yaml:"gauge_mode"`
+
+	ShutdownTimeout *configfile.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	ListenAddr      *string              `json:"listen_address" yaml:"listen_address"`
+
+	StatsDAddr   *string `json:"statsd_address" yaml:"statsd_address"`
+	StatsDTimers *string `json:"statsd_timers" yaml:"statsd_timers"`
+
+	Exec        []fileExecCommand    `json:"exec" yaml:"exec"`
+	ExecTimeout *configfile.Duration `json:"exec_timeout" yaml:"exec_timeout"`
+
+	CgroupRoot  *string   `json:"cgroup_root" yaml:"cgroup_root"`
+	CgroupPaths *[]string `json:"cgroup_paths" yaml:"cgroup_paths"`
+
+	LogFiles []fileLogFile `json:"log_files" yaml:"log_files"`
+
+	Processes []struct {
+		Name    string `json:"name" yaml:"name"`
+		Pattern string `json:"pattern" yaml:"pattern"`
+		Cmdline string `json:"cmdline" yaml:"cmdline"`
+		Pidfile string `json:"pidfile" yaml:"pidfile"`
+	} `json:"processes" yaml:"processes"`
+}
+
+// fileExecCommand описывает внешнюю команду в файле конфигурации.
+type fileExecCommand struct {
+	Name     string               `json:"name" yaml:"name"`
+	Command  []string             `json:"command" yaml:"command"`
+	Interval *configfile.Duration `json:"interval" yaml:"interval"`
+	Timeout  *configfile.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// fileLogFile описывает отслеживаемый журнал в файле конфигурации.
+type fileLogFile struct {
+	Path  string `json:"path" yaml:"path"`
+	Rules []struct {
+		Name    string `json:"name" yaml:"name"`
+		Pattern string `json:"pattern" yaml:"pattern"`
+		Gauge   string `json:"gauge" yaml:"gauge"`
+		Group   any    `json:"group" yaml:"group"` // Номер или имя группы
+	} `json:"rules" yaml:"rules"`
+}
+
+// execCommands возвращает внешние команды, заданные в файле.
+func (f *fileConfig) execCommands() []ExecCommand {
+	var commands []ExecCommand
+	for _, e := range f.Exec {
+		command := ExecCommand{Name: e.Name, Command: e.Command}
+		if e.Interval != nil {
+			command.Interval = e.Interval.Duration
+		}
+		if e.Timeout != nil {
+			command.Timeout = e.Timeout.Duration
+		}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+func setString(dst, src *string) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+// setList переносит список из файла в значение, заданное строкой через запятую.
+func setList(dst *string, src *[]string) {
+	if src != nil {
+		*dst = strings.Join(*src, ",")
+	}
+}
+
+// setDuration переносит интервал из файла без потери долей секунды.
+func setDuration(dst *time.Duration, src *configfile.Duration) {
+	if src != nil {
+		*dst = src.Duration
+	}
+}
+
+// logFiles возвращает отслеживаемые журналы, заданные в файле.
+func (f *fileConfig) logFiles() []LogFile {
+	var files []LogFile
+	for _, lf := range f.LogFiles {
+		file := LogFile{Path: lf.Path}
+		for _, r := range lf.Rules {
+			rule := LogRule{Name: r.Name, Pattern: r.Pattern, Gauge: r.Gauge}
+			if r.Group != nil {
+				rule.Group = fmt.Sprint(r.Group)
+			}
+			file.Rules = append(file.Rules, rule)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// processMatchers возвращает отслеживаемые процессы, заданные в файле.
+func (f *fileConfig) processMatchers() []ProcessMatcher {
+	var matchers []ProcessMatcher
+	for _, p := range f.Processes {
+		matchers = append(matchers, ProcessMatcher{Name: p.Name, Pattern: p.Pattern, Cmdline: p.Cmdline, Pidfile: p.Pidfile})
+	}
+	return matchers
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"fmt"
+	"go-metrics-server/internal/models"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AggregateModeSuffix — каждый агрегат отправляется отдельным gauge
+	// с суффиксом в имени (Alloc_max, Alloc_p95).
+	AggregateModeSuffix = "suffix"
+	// AggregateModeSummary — агрегаты окна отправляются одной сводкой.
+	AggregateModeSummary = "summary"
+)
+
+// maxWindowSamples ограничивает число значений в окне одного gauge,
+// если отправка долго не удается.
+const maxWindowSamples = 10000
+
+type aggregateKind int
+
+const (
+	aggregateLast aggregateKind = iota
+	aggregateMin
+	aggregateMax
+	aggregateMean
+	aggregatePercentile
+)
+
+type aggregate struct {
+	name       string
+	kind       aggregateKind
+	percentile float64 // Для aggregatePercentile, от 0 до 100
+}
+
+// Aggregator вычисляет агрегаты значений gauge за окно между отправками.
+type Aggregator struct {
+	aggregates []aggregate
+	mode       string
+}
+
+// NewAggregator создает агрегатор. Допустимые агрегаты: last, min, max, mean
+// и процентиль вида p95 или p99.9; mode — AggregateModeSuffix
+// или AggregateModeSummary.
+func NewAggregator(names []string, mode string) (*Aggregator, error) {
+	if mode != AggregateModeSuffix && mode != AggregateModeSummary {
+		return nil, fmt.Errorf("unknown aggregate mode %q", mode)
+	}
+
+	a := &Aggregator{mode: mode}
+	for _, name := range names {
+		agg, err := parseAggregate(name)
+		if err != nil {
+			return nil, err
+		}
+		a.aggregates = append(a.aggregates, agg)
+	}
+	return a, nil
+}
+
+func parseAggregate(name string) (aggregate, error) {
+	switch name {
+	case "last":
+		return aggregate{name: name, kind: aggregateLast}, nil
+	case "min":
+		return aggregate{name: name, kind: aggregateMin}, nil
+	case "max":
+		return aggregate{name: name, kind: aggregateMax}, nil
+	case "mean":
+		return aggregate{name: name, kind: aggregateMean}, nil
+	}
+
+	if p, ok := strings.CutPrefix(name, "p"); ok {
+		percentile, err := strconv.ParseFloat(p, 64)
+		if err == nil && percentile >= 0 && percentile <= 100 {
+			return aggregate{name: name, kind: aggregatePercentile, percentile: percentile}, nil
+		}
+	}
+	return aggregate{}, fmt.Errorf("unknown gauge aggregate %q", name)
+}
+
+// Aggregate возвращает метрики для gauge metric по значениям окна window
+// в порядке поступления. Значение last передается под исходным именем.
+func (a *Aggregator) Aggregate(metric models.Metrics, window []float64) []models.Metrics {
+	if len(window) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), window...)
+	sort.Float64s(sorted)
+
+	var result []models.Metrics
+	var quantiles []models.Quantile
+	for _, agg := range a.aggregates {
+		if agg.kind == aggregateLast {
+			result = append(result, gaugeMetric(metric, metric.ID, window[len(window)-1]))
+			continue
+		}
+
+		if a.mode == AggregateModeSummary {
+			switch agg.kind {
+			case aggregateMin:
+				quantiles = append(quantiles, models.Quantile{Quantile: 0, Value: sorted[0]})
+			case aggregateMax:
+				quantiles = append(quantiles, models.Quantile{Quantile: 1, Value: sorted[len(sorted)-1]})
+			case aggregatePercentile:
+				quantiles = append(quantiles, models.Quantile{Quantile: agg.percentile / 100, Value: percentile(sorted, agg.percentile)})
+			}
+			// Среднее в сводке вычисляется из Sum и Count
+			continue
+		}
+
+		var value float64
+		switch agg.kind {
+		case aggregateMin:
+			value = sorted[0]
+		case aggregateMax:
+			value = sorted[len(sorted)-1]
+		case aggregateMean:
+			value = sum(window) / float64(len(window))
+		case aggregatePercentile:
+			value = percentile(sorted, agg.percentile)
+		}
+		result = append(result, gaugeMetric(metric, metric.ID+"_"+agg.name, value))
+	}
+
+	if a.mode == AggregateModeSummary {
+		sort.Slice(quantiles, func(i, j int) bool { return quantiles[i].Quantile < quantiles[j].Quantile })
+		result = append(result, models.Metrics{
+			ID:      metric.ID,
+			MType:   "summary",
+			Labels:  metric.Labels,
+			Summary: &models.Summary{Quantiles: quantiles, Sum: sum(window), Count: uint64(len(window))},
+		})
+	}
+	return result
+}
+
+func gaugeMetric(metric models.Metrics, id string, value float64) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &value, Labels: metric.Labels}
+}
+
+func sum(values []float64) float64 {
+	var s float64
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
+
+// percentile возвращает p-й процентиль отсортированных значений
+// с линейной интерполяцией между соседними значениями.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"testing"
+
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregator_Suffix(t *testing.T) {
+	a, err := NewAggregator([]string{"last", "min", "max", "mean", "p50", "p90"}, AggregateModeSuffix)
+	require.NoError(t, err)
+
+	metric := models.Metrics{ID: "Alloc", MType: "gauge", Labels: map[string]string{"mountpoint": "/"}}
+	result := a.Aggregate(metric, []float64{5, 1, 3, 10, 6})
+
+	values := make(map[string]float64)
+	for _, m := range result {
+		assert.Equal(t, "gauge", m.MType)
+		assert.Equal(t, "/", m.Labels["mountpoint"])
+		values[m.ID] = *m.Value
+	}
+	assert.Equal(t, map[string]float64{
+		"Alloc":      6,
+		"Alloc_min":  1,
+		"Alloc_max":  10,
+		"Alloc_mean": 5,
+		"Alloc_p50":  5,
+		"Alloc_p90":  8.4,
+	}, values)
+}
+
+func TestAggregator_Summary(t *testing.T) {
+	a, err := NewAggregator([]string{"max", "p50", "min"}, AggregateModeSummary)
+	require.NoError(t, err)
+
+	result := a.Aggregate(models.Metrics{ID: "Alloc", MType: "gauge"}, []float64{4, 2, 6})
+	require.Len(t, result, 1)
+	assert.Equal(t, "summary", result[0].MType)
+	assert.Equal(t, &models.Summary{
+		Quantiles: []models.Quantile{{Quantile: 0, Value: 2}, {Quantile: 0.5, Value: 4}, {Quantile: 1, Value: 6}},
+		Sum:       12,
+		Count:     3,
+	}, result[0].Summary)
+}
+
+func TestNewAggregator_Invalid(t *testing.T) {
+	_, err := NewAggregator([]string{"median"}, AggregateModeSuffix)
+	assert.Error(t, err)
+	_, err = NewAggregator([]string{"p101"}, AggregateModeSuffix)
+	assert.Error(t, err)
+	_, err = NewAggregator([]string{"last"}, "histogram")
+	assert.Error(t, err)
+}
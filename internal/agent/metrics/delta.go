@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"go-metrics-server/internal/models"
+	"sync"
+)
+
+// DeltaTracker переводит накопительные значения счетчиков агента в дельты,
+// которые ожидает сервер. Значение считается подтвержденным только после Ack,
+// поэтому при неудачной отправке неотправленная дельта войдет в следующий пакет.
+// Обрабатываются все метрики типа counter, независимо от их источника.
+//
+// Первые значения счетчиков, накопленных до запуска агента (байты сети
+// с загрузки системы, процессорное время cgroup), запоминаются вызовом
+// Observe как базовые и не отправляются серверу как прирост. Остальные
+// счетчики, в том числе впервые появившиеся после нескольких опросов,
+// отправляются целиком.
+type DeltaTracker struct {
+	mu    sync.Mutex
+	acked map[counterKey]int64 // Подтвержденные или базовые накопительные значения
+}
+
+type counterKey struct {
+	id     string
+	labels string
+}
+
+func NewDeltaTracker() *DeltaTracker {
+	return &DeltaTracker{acked: make(map[counterKey]int64)}
+}
+
+// Deltas возвращает копию пакета, в которой накопительные значения счетчиков
+// заменены разницей с последним подтвержденным или базовым значением. Если
+// счетчик уменьшился (источник был перезапущен), дельтой считается текущее
+// значение. Счетчики без базового значения, например PollCount или счетчики
+// внешних команд, отправляются целиком.
+func (t *DeltaTracker) Deltas(batch []models.Metrics) []models.Metrics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]models.Metrics, len(batch))
+	copy(result, batch)
+
+	for i, metric := range result {
+		if metric.MType != "counter" || metric.Delta == nil {
+			continue
+		}
+		delta := *metric.Delta
+		if acked, ok := t.acked[keyOf(metric)]; ok && acked <= delta {
+			delta -= acked
+		}
+		result[i].Delta = &delta
+	}
+	return result
+}
+
+// Observe запоминает значения счетчиков, которых трекер еще не видел,
+// как базовые; для известных счетчиков ничего не меняется. Передаются
+// только счетчики, накопленные до запуска агента: базовым для них становится
+// первое полученное значение, даже если коллектор начал их возвращать
+// не с первого опроса.
+func (t *DeltaTracker) Observe(batch []models.Metrics) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, metric := range batch {
+		if metric.MType != "counter" || metric.Delta == nil {
+			continue
+		}
+		key := keyOf(metric)
+		if _, ok := t.acked[key]; !ok {
+			t.acked[key] = *metric.Delta
+		}
+	}
+}
+
+// Ack отмечает накопительные значения счетчиков пакета как доставленные.
+// Передается исходный пакет, а не результат Deltas.
+func (t *DeltaTracker) Ack(batch []models.Metrics) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, metric := range batch {
+		if metric.MType == "counter" && metric.Delta != nil {
+			t.acked[keyOf(metric)] = *metric.Delta
+		}
+	}
+}
+
+func keyOf(metric models.Metrics) counterKey {
+	return counterKey{id: metric.ID, labels: models.LabelsKey(metric.Labels)}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"testing"
+
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func counterBatch(values map[string]int64) []models.Metrics {
+	var batch []models.Metrics
+	for id, v := range values {
+		v := v
+		batch = append(batch, models.Metrics{ID: id, MType: "counter", Delta: &v})
+	}
+	return batch
+}
+
+func deltaOf(batch []models.Metrics, id string) int64 {
+	for _, m := range batch {
+		if m.ID == id {
+			return *m.Delta
+		}
+	}
+	return -1
+}
+
+func TestDeltaTracker(t *testing.T) {
+	tracker := NewDeltaTracker()
+
+	// Первая отправка — дельта равна накопленному значению
+	first := counterBatch(map[string]int64{"PollCount": 5})
+	assert.Equal(t, int64(5), deltaOf(tracker.Deltas(first), "PollCount"))
+	tracker.Ack(first)
+
+	// После подтверждения отправляется только прирост
+	second := counterBatch(map[string]int64{"PollCount": 8})
+	assert.Equal(t, int64(3), deltaOf(tracker.Deltas(second), "PollCount"))
+	// Исходный пакет не изменяется
+	assert.Equal(t, int64(8), deltaOf(second, "PollCount"))
+
+	// Отправка не удалась (Ack не вызван) — прирост переносится в следующий пакет
+	third := counterBatch(map[string]int64{"PollCount": 10, "Requests": 2})
+	deltas := tracker.Deltas(third)
+	assert.Equal(t, int64(5), deltaOf(deltas, "PollCount"))
+	assert.Equal(t, int64(2), deltaOf(deltas, "Requests"))
+	tracker.Ack(third)
+
+	// Сброс счетчика: дельтой считается текущее значение
+	reset := counterBatch(map[string]int64{"PollCount": 4})
+	assert.Equal(t, int64(4), deltaOf(tracker.Deltas(reset), "PollCount"))
+}
+
+func TestDeltaTracker_Observe(t *testing.T) {
+	// После перезапуска агента счетчик сети содержит байты с загрузки системы
+	tracker := NewDeltaTracker()
+	tracker.Observe(counterBatch(map[string]int64{"BytesRecv": 1 << 40}))
+	tracker.Observe(counterBatch(map[string]int64{"BytesRecv": 1<<40 + 100}))
+
+	// Отправляется только прирост с первого опроса
+	batch := counterBatch(map[string]int64{"BytesRecv": 1<<40 + 300, "PollCount": 3})
+	deltas := tracker.Deltas(batch)
+	assert.Equal(t, int64(300), deltaOf(deltas, "BytesRecv"))
+	// Счетчики без базового значения отправляются целиком
+	assert.Equal(t, int64(3), deltaOf(deltas, "PollCount"))
+	tracker.Ack(batch)
+
+	// Для известного счетчика Observe ничего не меняет
+	tracker.Observe(counterBatch(map[string]int64{"BytesRecv": 1<<40 + 500}))
+	assert.Equal(t, int64(200), deltaOf(tracker.Deltas(counterBatch(map[string]int64{"BytesRecv": 1<<40 + 500})), "BytesRecv"))
+}
+
+func TestDeltaTracker_Labels(t *testing.T) {
+	tracker := NewDeltaTracker()
+
+	a, b := int64(10), int64(20)
+	value := 1.5
+	batch := []models.Metrics{
+		{ID: "bytes", MType: "counter", Delta: &a, Labels: map[string]string{"dev": "a"}},
+		{ID: "bytes", MType: "counter", Delta: &b, Labels: map[string]string{"dev": "b"}},
+		{ID: "Alloc", MType: "gauge", Value: &value},
+	}
+	tracker.Ack(batch)
+
+	a2, b2 := int64(15), int64(21)
+	next := []models.Metrics{
+		{ID: "bytes", MType: "counter", Delta: &a2, Labels: map[string]string{"dev": "a"}},
+		{ID: "bytes", MType: "counter", Delta: &b2, Labels: map[string]string{"dev": "b"}},
+		{ID: "Alloc", MType: "gauge", Value: &value},
+	}
+	deltas := tracker.Deltas(next)
+	assert.Equal(t, int64(5), *deltas[0].Delta)
+	assert.Equal(t, int64(1), *deltas[1].Delta)
+	assert.Equal(t, 1.5, *deltas[2].Value)
+}
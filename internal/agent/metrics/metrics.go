@@ -1,89 +1,163 @@
 package metrics
 
 import (
-	"fmt"
-	"math/rand"
-	"runtime"
+	"context"
+	"errors"
+	"go-metrics-server/internal/agent/collector"
+	"go-metrics-server/internal/models"
+	"sort"
 	"sync"
 	"time"
-
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/mem"
 )
 
+// Metrics хранит последние значения, собранные коллекторами реестра,
+// и число выполненных опросов PollCount. Значения gauge накапливаются в окне
+// до успешной отправки и передаются агрегатами Aggregator; без агрегатора
+// отправляется последнее значение. Если задан Tracker, ему передаются
+// значения коллекторов со счетчиками, накопленными до запуска агента
+// (collector.SinceBootCollector), чтобы их первые значения стали базовыми.
 type Metrics struct {
-	PollCount   int64
-	RandomValue float64
-	Runtime     runtime.MemStats
-	mu          sync.Mutex
-	lastPoll    time.Time
+	PollCount  int64
+	Aggregator *Aggregator
+	Tracker    *DeltaTracker
+	registry   *collector.Registry
+	latest     map[string]models.Metrics // Последние значения по имени ряда
+	series     map[int]map[string]bool   // Ряды последнего опроса по номеру коллектора
+	windows    map[string][]sample       // Значения gauge за текущее окно
+	reported   int64                     // Номер опроса, на котором было сформировано последнее GetMetrics
+	mu         sync.Mutex
+	lastPoll   time.Time
+}
+
+// sample — значение gauge, полученное при опросе с номером poll.
+type sample struct {
+	poll  int64
+	value float64
 }
 
-func NewMetrics() *Metrics {
+func NewMetrics(registry *collector.Registry) *Metrics {
 	return &Metrics{
+		registry: registry,
+		latest:   make(map[string]models.Metrics),
+		series:   make(map[int]map[string]bool),
+		windows:  make(map[string][]sample),
 		lastPoll: time.Now(),
 	}
 }
 
-func (m *Metrics) Update() {
+// Update опрашивает коллекторы. Успешный опрос коллектора заменяет
+// все его ряды: ряды, которые он больше не возвращает (отключенный диск,
+// завершившийся процесс), перестают отправляться. Метрики, собранные
+// коллектором до ошибки, добавляются к прежним, а ошибка возвращается
+// вызывающей стороне.
+func (m *Metrics) Update(ctx context.Context) error {
+	results := m.registry.CollectEach(ctx)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.PollCount++
-	m.RandomValue = rand.Float64()
-	runtime.ReadMemStats(&m.Runtime)
+	var errs []error
+	for i, result := range results {
+		if m.Tracker != nil && result.SinceBoot {
+			m.Tracker.Observe(result.Metrics)
+		}
+		series := make(map[string]bool, len(result.Metrics))
+		for _, metric := range result.Metrics {
+			name := models.SeriesName(metric.ID, metric.Labels)
+			series[name] = true
+			m.latest[name] = metric
+			if metric.MType == "gauge" && metric.Value != nil {
+				window := append(m.windows[name], sample{poll: m.PollCount, value: *metric.Value})
+				if len(window) > maxWindowSamples {
+					window = window[len(window)-maxWindowSamples:]
+				}
+				m.windows[name] = window
+			}
+		}
+
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+			for name := range m.series[i] {
+				series[name] = true
+			}
+		} else {
+			for name := range m.series[i] {
+				if !series[name] {
+					delete(m.latest, name)
+					delete(m.windows, name)
+				}
+			}
+		}
+		m.series[i] = series
+	}
 	m.lastPoll = time.Now()
+
+	return errors.Join(errs...)
 }
 
-func (m *Metrics) GetMetrics() map[string]interface{} {
+// GetMetrics возвращает метрики для отправки, упорядоченные по имени ряда.
+// Окно gauge сбрасывается только вызовом ResetWindow после успешной отправки.
+func (m *Metrics) GetMetrics() []models.Metrics {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	metrics := map[string]interface{}{
-		"PollCount":   m.PollCount,
-		"RandomValue": m.RandomValue,
-	}
+	m.reported = m.PollCount
+	return m.snapshot()
+}
 
-	metrics["Alloc"] = float64(m.Runtime.Alloc)
-	metrics["BuckHashSys"] = float64(m.Runtime.BuckHashSys)
-	metrics["Frees"] = float64(m.Runtime.Frees)
-	metrics["GCCPUFraction"] = m.Runtime.GCCPUFraction
-	metrics["GCSys"] = float64(m.Runtime.GCSys)
-	metrics["HeapAlloc"] = float64(m.Runtime.HeapAlloc)
-	metrics["HeapIdle"] = float64(m.Runtime.HeapIdle)
-	metrics["HeapInuse"] = float64(m.Runtime.HeapInuse)
-	metrics["HeapObjects"] = float64(m.Runtime.HeapObjects)
-	metrics["HeapReleased"] = float64(m.Runtime.HeapReleased)
-	metrics["HeapSys"] = float64(m.Runtime.HeapSys)
-	metrics["LastGC"] = float64(m.Runtime.LastGC)
-	metrics["Lookups"] = float64(m.Runtime.Lookups)
-	metrics["MCacheInuse"] = float64(m.Runtime.MCacheInuse)
-	metrics["MCacheSys"] = float64(m.Runtime.MCacheSys)
-	metrics["MSpanInuse"] = float64(m.Runtime.MSpanInuse)
-	metrics["MSpanSys"] = float64(m.Runtime.MSpanSys)
-	metrics["Mallocs"] = float64(m.Runtime.Mallocs)
-	metrics["NextGC"] = float64(m.Runtime.NextGC)
-	metrics["NumForcedGC"] = float64(m.Runtime.NumForcedGC)
-	metrics["NumGC"] = float64(m.Runtime.NumGC)
-	metrics["OtherSys"] = float64(m.Runtime.OtherSys)
-	metrics["PauseTotalNs"] = float64(m.Runtime.PauseTotalNs)
-	metrics["StackInuse"] = float64(m.Runtime.StackInuse)
-	metrics["StackSys"] = float64(m.Runtime.StackSys)
-	metrics["Sys"] = float64(m.Runtime.Sys)
-	metrics["TotalAlloc"] = float64(m.Runtime.TotalAlloc)
-
-	v, err := mem.VirtualMemory()
-	if err == nil {
-		metrics["TotalMemory"] = float64(v.Total)
-		metrics["FreeMemory"] = float64(v.Free)
+// Snapshot возвращает те же метрики, что и GetMetrics, но не влияет
+// на сброс окна gauge. Используется для чтения метрик без отправки.
+func (m *Metrics) Snapshot() []models.Metrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.snapshot()
+}
+
+func (m *Metrics) snapshot() []models.Metrics {
+	pollCount := m.PollCount
+	metrics := []models.Metrics{{ID: "PollCount", MType: "counter", Delta: &pollCount}}
+
+	names := make([]string, 0, len(m.latest))
+	for name := range m.latest {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	for _, name := range names {
+		metric := m.latest[name]
+		samples := m.windows[name]
+		// Если с прошлой отправки опросов не было, отправляется последнее значение
+		if metric.MType != "gauge" || m.Aggregator == nil || len(samples) == 0 {
+			metrics = append(metrics, metric)
+			continue
+		}
 
-	percents, err := cpu.Percent(0, true)
-	if err == nil {
-		for i, percent := range percents {
-			metrics[fmt.Sprintf("CPUutilization%d", i+1)] = percent
+		window := make([]float64, len(samples))
+		for i, s := range samples {
+			window[i] = s.value
 		}
+		metrics = append(metrics, m.Aggregator.Aggregate(metric, window)...)
 	}
 
 	return metrics
 }
+
+// ResetWindow удаляет из окна gauge значения, вошедшие в последний результат
+// GetMetrics. Значения опросов, выполненных после него, сохраняются.
+func (m *Metrics) ResetWindow() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, samples := range m.windows {
+		n := 0
+		for n < len(samples) && samples[n].poll <= m.reported {
+			n++
+		}
+		if n == len(samples) {
+			delete(m.windows, name)
+		} else {
+			m.windows[name] = samples[n:]
+		}
+	}
+}
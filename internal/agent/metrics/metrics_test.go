@@ -1,25 +1,294 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"go-metrics-server/internal/agent/collector"
+	"go-metrics-server/internal/agent/config"
+	"go-metrics-server/internal/models"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+type stubCollector struct {
+	metrics []models.Metrics
+	err     error
+}
+
+func (c stubCollector) Name() string { return "stub" }
+
+func (c stubCollector) Collect(context.Context) ([]models.Metrics, error) {
+	return c.metrics, c.err
+}
+
+func findMetric(metrics []models.Metrics, id string) (models.Metrics, bool) {
+	for _, m := range metrics {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return models.Metrics{}, false
+}
+
 func TestMetrics_Update(t *testing.T) {
-	m := NewMetrics()
-	m.Update()
+	m := NewMetrics(collector.NewRegistry(collector.Random{}))
+	assert.NoError(t, m.Update(context.Background()))
 
 	assert.Equal(t, int64(1), m.PollCount)
-	assert.True(t, m.RandomValue >= 0 && m.RandomValue <= 1)
+	random, ok := findMetric(m.GetMetrics(), "RandomValue")
+	assert.True(t, ok)
+	assert.True(t, *random.Value >= 0 && *random.Value <= 1)
 }
 
 func TestMetrics_GetMetrics(t *testing.T) {
-	m := NewMetrics()
-	m.Update()
+	m := NewMetrics(collector.NewRegistry(collector.Runtime{}, collector.Random{}))
+	m.Update(context.Background())
 
 	metrics := m.GetMetrics()
 	assert.NotEmpty(t, metrics)
-	assert.Contains(t, metrics, "PollCount")
-	assert.Contains(t, metrics, "RandomValue")
+	_, ok := findMetric(metrics, "PollCount")
+	assert.True(t, ok)
+	_, ok = findMetric(metrics, "RandomValue")
+	assert.True(t, ok)
+	_, ok = findMetric(metrics, "HeapAlloc")
+	assert.True(t, ok)
+}
+
+func TestMetrics_UpdateKeepsPartialResults(t *testing.T) {
+	value := 1.5
+	failing := stubCollector{
+		metrics: []models.Metrics{{ID: "Partial", MType: "gauge", Value: &value}},
+		err:     errors.New("device busy"),
+	}
+	m := NewMetrics(collector.NewRegistry(failing))
+
+	assert.Error(t, m.Update(context.Background()))
+	partial, ok := findMetric(m.GetMetrics(), "Partial")
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, *partial.Value)
+}
+
+// seriesCollector возвращает gauge с перечисленными именами.
+type seriesCollector struct {
+	ids []string
+	err error
+}
+
+func (c *seriesCollector) Name() string { return "series" }
+
+func (c *seriesCollector) Collect(context.Context) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+	for _, id := range c.ids {
+		value := 1.0
+		metrics = append(metrics, models.Metrics{ID: id, MType: "gauge", Value: &value})
+	}
+	return metrics, c.err
+}
+
+func TestMetrics_UpdateReplacesSeries(t *testing.T) {
+	c := &seriesCollector{ids: []string{"DiskA", "DiskB"}}
+	other := &seriesCollector{ids: []string{"Other"}}
+	m := NewMetrics(collector.NewRegistry(c, other))
+	assert.NoError(t, m.Update(context.Background()))
+
+	// Ряд, который коллектор перестал возвращать, больше не отправляется
+	c.ids = []string{"DiskA"}
+	other.ids = []string{"Other"}
+	assert.NoError(t, m.Update(context.Background()))
+	metrics := m.GetMetrics()
+	_, ok := findMetric(metrics, "DiskB")
+	assert.False(t, ok)
+	_, ok = findMetric(metrics, "DiskA")
+	assert.True(t, ok)
+	_, ok = findMetric(metrics, "Other")
+	assert.True(t, ok)
+
+	// При ошибке коллектора прежние ряды сохраняются до успешного опроса
+	c.ids, c.err = nil, errors.New("device busy")
+	assert.Error(t, m.Update(context.Background()))
+	_, ok = findMetric(m.GetMetrics(), "DiskA")
+	assert.True(t, ok)
+
+	c.err = nil
+	assert.NoError(t, m.Update(context.Background()))
+	_, ok = findMetric(m.GetMetrics(), "DiskA")
+	assert.False(t, ok)
+	assert.Empty(t, m.windows["DiskA"])
+}
+
+// cumulativeCollector возвращает накопительный счетчик с заданным значением.
+type cumulativeCollector struct {
+	value int64
+}
+
+func (c *cumulativeCollector) Name() string { return "cumulative" }
+
+func (c *cumulativeCollector) CountersSinceBoot() bool { return true }
+
+func (c *cumulativeCollector) Collect(context.Context) ([]models.Metrics, error) {
+	value := c.value
+	return []models.Metrics{{ID: "BytesSent", MType: "counter", Delta: &value}}, nil
+}
+
+func TestMetrics_TrackerBaseline(t *testing.T) {
+	c := &cumulativeCollector{value: 1_000_000}
+	m := NewMetrics(collector.NewRegistry(c))
+	m.Tracker = NewDeltaTracker()
+
+	// Первая отправка после запуска не содержит накопленного до него
+	assert.NoError(t, m.Update(context.Background()))
+	c.value += 10
+	assert.NoError(t, m.Update(context.Background()))
+	snapshot := m.GetMetrics()
+	deltas := m.Tracker.Deltas(snapshot)
+	sent, _ := findMetric(deltas, "BytesSent")
+	assert.Equal(t, int64(10), *sent.Delta)
+	pollCount, _ := findMetric(deltas, "PollCount")
+	assert.Equal(t, int64(2), *pollCount.Delta)
+}
+
+// lateCounterCollector начинает возвращать счетчик Jobs со второго опроса,
+// как внешняя команда, которая впервые выполнилась успешно.
+type lateCounterCollector struct {
+	polls     int64
+	sinceBoot bool
+}
+
+func (c *lateCounterCollector) Name() string { return "late" }
+
+func (c *lateCounterCollector) CountersSinceBoot() bool { return c.sinceBoot }
+
+func (c *lateCounterCollector) Collect(context.Context) ([]models.Metrics, error) {
+	c.polls++
+	if c.polls == 1 {
+		return nil, errors.New("not ready")
+	}
+	value := c.polls * 10
+	return []models.Metrics{{ID: "Jobs", MType: "counter", Delta: &value}}, nil
+}
+
+func TestMetrics_TrackerLateCounter(t *testing.T) {
+	// Счетчик, посчитанный после запуска агента, отправляется целиком
+	c := &lateCounterCollector{}
+	m := NewMetrics(collector.NewRegistry(c))
+	m.Tracker = NewDeltaTracker()
+	assert.Error(t, m.Update(context.Background()))
+	assert.NoError(t, m.Update(context.Background()))
+	jobs, ok := findMetric(m.Tracker.Deltas(m.GetMetrics()), "Jobs")
+	require.True(t, ok)
+	assert.Equal(t, int64(20), *jobs.Delta)
+
+	// Счетчик, накопленный до запуска, получает базовое значение в первом
+	// удачном опросе, даже если первый опрос коллектора не удался
+	c = &lateCounterCollector{sinceBoot: true}
+	m = NewMetrics(collector.NewRegistry(c))
+	m.Tracker = NewDeltaTracker()
+	assert.Error(t, m.Update(context.Background()))
+	assert.NoError(t, m.Update(context.Background()))
+	assert.NoError(t, m.Update(context.Background()))
+	jobs, _ = findMetric(m.Tracker.Deltas(m.GetMetrics()), "Jobs")
+	assert.Equal(t, int64(10), *jobs.Delta)
+}
+
+// sequenceCollector возвращает значения gauge по очереди, по одному за опрос.
+type sequenceCollector struct {
+	values []float64
+}
+
+func (c *sequenceCollector) Name() string { return "sequence" }
+
+func (c *sequenceCollector) Collect(context.Context) ([]models.Metrics, error) {
+	value := c.values[0]
+	c.values = c.values[1:]
+	return []models.Metrics{{ID: "Load", MType: "gauge", Value: &value}}, nil
+}
+
+func TestMetrics_GaugeWindow(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics(collector.NewRegistry(&sequenceCollector{values: []float64{1, 9, 4, 2, 3}}))
+	aggregator, err := NewAggregator([]string{"last", "max"}, AggregateModeSuffix)
+	assert.NoError(t, err)
+	m.Aggregator = aggregator
+
+	m.Update(ctx)
+	m.Update(ctx)
+	m.Update(ctx)
+
+	// Пик между отправками виден в агрегате max
+	metrics := m.GetMetrics()
+	last, _ := findMetric(metrics, "Load")
+	peak, _ := findMetric(metrics, "Load_max")
+	assert.Equal(t, 4.0, *last.Value)
+	assert.Equal(t, 9.0, *peak.Value)
+
+	// Без успешной отправки окно не сбрасывается
+	m.Update(ctx)
+	peak, _ = findMetric(m.GetMetrics(), "Load_max")
+	assert.Equal(t, 9.0, *peak.Value)
+
+	// Значения, собранные после GetMetrics, остаются в окне после сброса
+	m.Update(ctx)
+	m.ResetWindow()
+	metrics = m.GetMetrics()
+	peak, _ = findMetric(metrics, "Load_max")
+	assert.Equal(t, 3.0, *peak.Value)
+
+	// Без новых опросов отправляется последнее значение
+	m.ResetWindow()
+	metrics = m.GetMetrics()
+	last, _ = findMetric(metrics, "Load")
+	assert.Equal(t, 3.0, *last.Value)
+	_, ok := findMetric(metrics, "Load_max")
+	assert.False(t, ok)
+}
+
+func TestMetrics_SnapshotDoesNotAffectWindow(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics(collector.NewRegistry(&sequenceCollector{values: []float64{5, 1}}))
+	aggregator, err := NewAggregator([]string{"max"}, AggregateModeSuffix)
+	assert.NoError(t, err)
+	m.Aggregator = aggregator
+
+	m.Update(ctx)
+	m.GetMetrics()
+	m.Update(ctx)
+
+	// Чтение снимка после отправки не сдвигает границу окна
+	peak, _ := findMetric(m.Snapshot(), "Load_max")
+	assert.Equal(t, 5.0, *peak.Value)
+	m.ResetWindow()
+
+	peak, _ = findMetric(m.Snapshot(), "Load_max")
+	assert.Equal(t, 1.0, *peak.Value)
+}
+
+func TestMetrics_CgroupCounterBaseline(t *testing.T) {
+	root := t.TempDir()
+	stat := filepath.Join(root, "cpu.stat")
+	// Процессорное время, израсходованное контейнером до запуска агента
+	require.NoError(t, os.WriteFile(stat, []byte("usage_usec 90000000\nnr_throttled 40\n"), 0o644))
+
+	m := NewMetrics(collector.NewRegistry(collector.NewCgroup(&config.Config{CgroupRoot: root, CgroupPaths: []string{"/"}})))
+	m.Tracker = NewDeltaTracker()
+	assert.NoError(t, m.Update(context.Background()))
+
+	snapshot := m.GetMetrics()
+	deltas := m.Tracker.Deltas(snapshot)
+	usage, ok := findMetric(deltas, "CgroupCPUUsageUsec")
+	require.True(t, ok)
+	assert.Equal(t, int64(0), *usage.Delta)
+	m.Tracker.Ack(snapshot)
+
+	require.NoError(t, os.WriteFile(stat, []byte("usage_usec 90250000\nnr_throttled 42\n"), 0o644))
+	assert.NoError(t, m.Update(context.Background()))
+	deltas = m.Tracker.Deltas(m.GetMetrics())
+	usage, _ = findMetric(deltas, "CgroupCPUUsageUsec")
+	assert.Equal(t, int64(250000), *usage.Delta)
+	throttled, _ := findMetric(deltas, "CgroupCPUThrottledPeriods")
+	assert.Equal(t, int64(2), *throttled.Delta)
 }
@@ -0,0 +1,266 @@
+// Package outbox реализует очередь неотправленных пакетов метрик агента.
+// Пакеты хранятся на диске по одному файлу на пакет и отправляются в порядке
+// поступления. При переполнении очереди самые старые пакеты объединяются:
+// дельты счетчиков складываются, поэтому данные счетчиков не теряются.
+//
+// Каждый пакет получает идентификатор, который сервер использует как ключ
+// идемпотентности. Пакет, который уже пытались отправить, не объединяется
+// с другими: сервер мог его применить, и повтор должен идти с тем же ключом.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const fileExt = ".json"
+
+// ErrEmpty возвращается, если в очереди нет пакетов.
+var ErrEmpty = errors.New("outbox is empty")
+
+// Outbox — ограниченная по числу пакетов очередь. Если каталог не задан,
+// очередь хранится только в памяти.
+type Outbox struct {
+	mu         sync.Mutex
+	dir        string
+	maxBatches int
+	seqs       []uint64          // Номера пакетов в порядке поступления
+	entries    map[uint64]*entry // Содержимое пакетов
+	inflight   uint64            // Пакет, который сейчас отправляется
+	next       uint64
+	drainMu    sync.Mutex
+}
+
+// entry — пакет в очереди, в таком виде он хранится в файле.
+type entry struct {
+	models.Batch
+	Attempted bool `json:"attempted,omitempty"` // Пакет уже отправлялся на сервер
+}
+
+// Open открывает очередь в каталоге dir, загружая сохраненные ранее пакеты.
+// maxBatches ограничивает число пакетов в очереди.
+func Open(dir string, maxBatches int) (*Outbox, error) {
+	if maxBatches < 1 {
+		maxBatches = 1
+	}
+	o := &Outbox{
+		dir:        dir,
+		maxBatches: maxBatches,
+		entries:    make(map[uint64]*entry),
+		next:       1,
+	}
+	if dir == "" {
+		return o, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox directory: %w", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, fileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outbox batch: %w", err)
+		}
+		var e entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			// Поврежденный пакет (например, после сбоя во время записи) пропускаем
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+
+		o.seqs = append(o.seqs, seq)
+		o.entries[seq] = &e
+		if seq >= o.next {
+			o.next = seq + 1
+		}
+	}
+	sort.Slice(o.seqs, func(i, j int) bool { return o.seqs[i] < o.seqs[j] })
+
+	if err := o.compact(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Push добавляет пакет в конец очереди, присваивая ему новый идентификатор.
+func (o *Outbox) Push(metrics []models.Metrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	seq := o.next
+	e := &entry{Batch: models.Batch{ID: models.NewBatchID(), Metrics: metrics}}
+	if err := o.write(seq, e); err != nil {
+		return err
+	}
+	o.next++
+	o.seqs = append(o.seqs, seq)
+	o.entries[seq] = e
+
+	return o.compact()
+}
+
+// Len возвращает число пакетов в очереди.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.seqs)
+}
+
+// Drain отправляет пакеты по порядку функцией send и удаляет успешно
+// отправленные. Отправка прекращается на первой ошибке, которая и возвращается
+// вместе с ошибками отклоненных пакетов; неотправленные пакеты остаются
+// в очереди. Пакет, отклоненный сервером (models.ErrBatchRejected), удаляется,
+// чтобы не блокировать очередь, и отправка продолжается.
+func (o *Outbox) Drain(send func(models.Batch) error) error {
+	o.drainMu.Lock()
+	defer o.drainMu.Unlock()
+
+	var rejected []error
+	for {
+		o.mu.Lock()
+		if len(o.seqs) == 0 {
+			o.mu.Unlock()
+			return errors.Join(rejected...)
+		}
+		seq := o.seqs[0]
+		e := o.entries[seq]
+		if !e.Attempted {
+			e.Attempted = true
+			if err := o.write(seq, e); err != nil {
+				o.mu.Unlock()
+				return errors.Join(append(rejected, err)...)
+			}
+		}
+		o.inflight = seq
+		o.mu.Unlock()
+
+		err := send(e.Batch)
+
+		o.mu.Lock()
+		o.inflight = 0
+		if errors.Is(err, models.ErrBatchRejected) {
+			rejected = append(rejected, fmt.Errorf("batch %s dropped: %w", e.ID, err))
+		} else if err != nil {
+			o.mu.Unlock()
+			return errors.Join(append(rejected, err)...)
+		}
+		removeErr := o.remove(seq)
+		o.mu.Unlock()
+		if removeErr != nil {
+			return errors.Join(append(rejected, removeErr)...)
+		}
+	}
+}
+
+// compact объединяет самые старые пакеты, пока очередь превышает лимит.
+// Пакеты, которые отправляются или уже отправлялись, не объединяются,
+// чтобы их данные не были применены сервером дважды. Объединенный пакет
+// получает новый идентификатор.
+func (o *Outbox) compact() error {
+	for len(o.seqs) > o.maxBatches {
+		first := 0
+		for first < len(o.seqs) && (o.seqs[first] == o.inflight || o.entries[o.seqs[first]].Attempted) {
+			first++
+		}
+		if first+1 >= len(o.seqs) {
+			return nil
+		}
+
+		older, newer := o.seqs[first], o.seqs[first+1]
+		merged := &entry{Batch: models.Batch{
+			ID:      models.NewBatchID(),
+			Metrics: models.MergeMetrics(o.entries[older].Metrics, o.entries[newer].Metrics),
+		}}
+		if err := o.write(newer, merged); err != nil {
+			return err
+		}
+		o.entries[newer] = merged
+		if err := o.remove(older); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Outbox) remove(seq uint64) error {
+	for i, s := range o.seqs {
+		if s == seq {
+			o.seqs = append(o.seqs[:i], o.seqs[i+1:]...)
+			break
+		}
+	}
+	delete(o.entries, seq)
+
+	if o.dir == "" {
+		return nil
+	}
+	if err := os.Remove(o.path(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove outbox batch: %w", err)
+	}
+	return nil
+}
+
+// write атомарно сохраняет пакет: данные пишутся во временный файл,
+// который затем переименовывается.
+func (o *Outbox) write(seq uint64, e *entry) error {
+	if o.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox batch: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(o.dir, "batch-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create outbox batch: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write outbox batch: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync outbox batch: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close outbox batch: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path(seq)); err != nil {
+		return fmt.Errorf("failed to save outbox batch: %w", err)
+	}
+	return nil
+}
+
+func (o *Outbox) path(seq uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d%s", seq, fileExt))
+}
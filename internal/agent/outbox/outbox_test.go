@@ -0,0 +1,196 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gauge(id string, value float64) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &value}
+}
+
+func counter(id string, delta int64) models.Metrics {
+	return models.Metrics{ID: id, MType: "counter", Delta: &delta}
+}
+
+// drainAll забирает все пакеты из очереди.
+func drainAll(t *testing.T, o *Outbox) [][]models.Metrics {
+	t.Helper()
+	var sent [][]models.Metrics
+	require.NoError(t, o.Drain(func(batch models.Batch) error {
+		sent = append(sent, batch.Metrics)
+		return nil
+	}))
+	return sent
+}
+
+func TestOutbox_OrderAndRetry(t *testing.T) {
+	o, err := Open(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	require.NoError(t, o.Push([]models.Metrics{gauge("a", 1)}))
+	require.NoError(t, o.Push([]models.Metrics{gauge("a", 2)}))
+	require.NoError(t, o.Push([]models.Metrics{gauge("a", 3)}))
+
+	// Первая отправка успешна, вторая — нет: очередь останавливается на ошибке
+	calls := 0
+	sendErr := errors.New("server unavailable")
+	err = o.Drain(func(batch models.Batch) error {
+		calls++
+		if calls == 2 {
+			return sendErr
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, sendErr)
+	assert.Equal(t, 2, o.Len())
+
+	sent := drainAll(t, o)
+	require.Len(t, sent, 2)
+	assert.Equal(t, 2.0, *sent[0][0].Value)
+	assert.Equal(t, 3.0, *sent[1][0].Value)
+	assert.Equal(t, 0, o.Len())
+}
+
+func TestOutbox_RejectedBatchIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	o, err := Open(dir, 10)
+	require.NoError(t, err)
+	require.NoError(t, o.Push([]models.Metrics{gauge("a", 1)}))
+	require.NoError(t, o.Push([]models.Metrics{gauge("a", 2)}))
+	require.NoError(t, o.Push([]models.Metrics{gauge("a", 3)}))
+
+	// Отклоненный пакет удаляется, отправка продолжается со следующего
+	var sent []float64
+	err = o.Drain(func(batch models.Batch) error {
+		value := *batch.Metrics[0].Value
+		if value == 1 {
+			return fmt.Errorf("unexpected status: 400: %w", models.ErrBatchRejected)
+		}
+		sent = append(sent, value)
+		return nil
+	})
+	assert.ErrorIs(t, err, models.ErrBatchRejected)
+	assert.Equal(t, []float64{2, 3}, sent)
+	assert.Equal(t, 0, o.Len())
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestOutbox_Persistence(t *testing.T) {
+	dir := t.TempDir()
+
+	o, err := Open(dir, 10)
+	require.NoError(t, err)
+	require.NoError(t, o.Push([]models.Metrics{counter("c", 1)}))
+	require.NoError(t, o.Push([]models.Metrics{counter("c", 2)}))
+
+	// Поврежденный файл не мешает открыть очередь
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000099.json"), []byte("{broken"), 0o644))
+
+	reopened, err := Open(dir, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reopened.Len())
+
+	sent := drainAll(t, reopened)
+	require.Len(t, sent, 2)
+	assert.Equal(t, int64(1), *sent[0][0].Delta)
+	assert.Equal(t, int64(2), *sent[1][0].Delta)
+
+	// Новые пакеты получают номера после уже использованных
+	require.NoError(t, reopened.Push([]models.Metrics{counter("c", 3)}))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "00000000000000000003.json", entries[0].Name())
+}
+
+func TestOutbox_Compaction(t *testing.T) {
+	o, err := Open(t.TempDir(), 2)
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, o.Push([]models.Metrics{counter("PollCount", int64(i)), gauge("Alloc", float64(i))}))
+	}
+	assert.Equal(t, 2, o.Len())
+
+	sent := drainAll(t, o)
+	require.Len(t, sent, 2)
+
+	// Дельты счетчиков не теряются при объединении пакетов
+	var total int64
+	for _, batch := range sent {
+		for _, m := range batch {
+			if m.MType == "counter" {
+				total += *m.Delta
+			}
+		}
+	}
+	assert.Equal(t, int64(15), total)
+	assert.Equal(t, 4.0, *sent[0][1].Value)
+	assert.Equal(t, 5.0, *sent[1][1].Value)
+}
+
+func TestOutbox_InflightBatchIsNotMerged(t *testing.T) {
+	o, err := Open("", 1)
+	require.NoError(t, err)
+	require.NoError(t, o.Push([]models.Metrics{counter("c", 1)}))
+
+	var sent []int64
+	err = o.Drain(func(batch models.Batch) error {
+		sent = append(sent, *batch.Metrics[0].Delta)
+		if len(sent) == 1 {
+			// Пока первый пакет отправляется, поступают новые
+			require.NoError(t, o.Push([]models.Metrics{counter("c", 2)}))
+			require.NoError(t, o.Push([]models.Metrics{counter("c", 3)}))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 5}, sent)
+}
+
+func TestOutbox_RetryKeepsBatchID(t *testing.T) {
+	dir := t.TempDir()
+	o, err := Open(dir, 1)
+	require.NoError(t, err)
+	require.NoError(t, o.Push([]models.Metrics{counter("c", 1)}))
+
+	var firstID string
+	sendErr := errors.New("timeout")
+	err = o.Drain(func(batch models.Batch) error {
+		firstID = batch.ID
+		return sendErr
+	})
+	require.ErrorIs(t, err, sendErr)
+	require.NotEmpty(t, firstID)
+
+	// Пакет, который уже отправлялся, не объединяется с новыми
+	require.NoError(t, o.Push([]models.Metrics{counter("c", 2)}))
+	require.NoError(t, o.Push([]models.Metrics{counter("c", 3)}))
+	assert.Equal(t, 2, o.Len())
+
+	// Повтор после перезапуска идет с тем же идентификатором
+	reopened, err := Open(dir, 1)
+	require.NoError(t, err)
+	var batches []models.Batch
+	require.NoError(t, reopened.Drain(func(batch models.Batch) error {
+		batches = append(batches, batch)
+		return nil
+	}))
+	require.Len(t, batches, 2)
+	assert.Equal(t, firstID, batches[0].ID)
+	assert.Equal(t, int64(1), *batches[0].Metrics[0].Delta)
+	assert.NotEqual(t, firstID, batches[1].ID)
+	assert.Equal(t, int64(5), *batches[1].Metrics[0].Delta)
+}
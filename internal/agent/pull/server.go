@@ -0,0 +1,68 @@
+// Package pull реализует режим, в котором агент сам отдает текущие метрики
+// по HTTP: в JSON ([]models.Metrics) и в текстовом формате Prometheus.
+// Режим работает независимо от отправки метрик на сервер.
+package pull
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-metrics-server/internal/models"
+	"go-metrics-server/internal/prometheus"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Source возвращает текущий снимок метрик агента.
+type Source func() []models.Metrics
+
+// NewServer создает HTTP-сервер на addr с маршрутами:
+//
+//	GET /metrics      — текстовый формат Prometheus;
+//	GET /metrics.json — JSON-массив метрик.
+func NewServer(addr string, source Source) *http.Server {
+	r := chi.NewRouter()
+	r.Get("/metrics", prometheusHandler(source))
+	r.Get("/metrics.json", jsonHandler(source))
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+func prometheusHandler(source Source) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := prometheus.WriteText(&buf, source()); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to encode metrics: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", prometheus.ContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
+
+func jsonHandler(source Source) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics := source()
+		if metrics == nil {
+			metrics = []models.Metrics{}
+		}
+
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(metrics); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to encode metrics: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,62 @@
+package pull
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-metrics-server/internal/models"
+	"go-metrics-server/internal/prometheus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	value := 12.5
+	delta := int64(3)
+	source := func() []models.Metrics {
+		return []models.Metrics{
+			{ID: "Alloc", MType: "gauge", Value: &value},
+			{ID: "PollCount", MType: "counter", Delta: &delta, Labels: map[string]string{"interface": "eth0"}},
+		}
+	}
+
+	ts := httptest.NewServer(NewServer("", source).Handler)
+	defer ts.Close()
+
+	t.Run("prometheus", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/metrics")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, prometheus.ContentType, resp.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "Alloc 12.5\n")
+		assert.Contains(t, string(body), `PollCount{interface="eth0"} 3`)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/metrics.json")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var metrics []models.Metrics
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&metrics))
+		require.Len(t, metrics, 2)
+		assert.Equal(t, 12.5, *metrics[0].Value)
+	})
+
+	t.Run("only GET", func(t *testing.T) {
+		resp, err := http.Post(ts.URL+"/metrics", "text/plain", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
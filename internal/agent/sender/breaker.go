@@ -0,0 +1,136 @@
+package sender
+
+import (
+	"errors"
+	"go-metrics-server/internal/models"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается без обращения к серверу, пока цепь разомкнута.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState — состояние автомата защиты.
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // Запросы проходят
+	BreakerHalfOpen                     // Пропускается пробный запрос
+	BreakerOpen                         // Запросы отклоняются
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
+
+// CircuitBreaker размыкает цепь после FailureThreshold ошибок подряд и
+// не обращается к серверу OpenTimeout. Затем пропускается один пробный
+// запрос: при успехе цепь замыкается, при ошибке снова размыкается.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trips    int64 // Сколько раз цепь размыкалась
+	probing  bool  // Пробный запрос уже выполняется
+	now      func() time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// Allow проверяет, можно ли выполнить запрос.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.OpenTimeout {
+		b.state = BreakerHalfOpen
+	}
+	switch b.state {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Success отмечает успешный запрос.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure отмечает запрос, завершившийся ошибкой сервера или сети.
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.FailureThreshold {
+		if b.state != BreakerOpen {
+			b.trips++
+		}
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Abort снимает отметку пробного запроса, результат которого неизвестен
+// (например, запрос был отменен).
+func (b *CircuitBreaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State возвращает текущее состояние.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Metrics возвращает состояние автомата для отправки агентом:
+// gauge CircuitBreakerState (0 — замкнута, 1 — пробный запрос, 2 — разомкнута)
+// и counter CircuitBreakerTrips с числом размыканий.
+func (b *CircuitBreaker) Metrics(labels map[string]string) []models.Metrics {
+	state := float64(b.State())
+
+	b.mu.Lock()
+	trips := b.trips
+	b.mu.Unlock()
+
+	return []models.Metrics{
+		{ID: "CircuitBreakerState", MType: "gauge", Value: &state, Labels: labels},
+		{ID: "CircuitBreakerTrips", MType: "counter", Delta: &trips, Labels: labels},
+	}
+}
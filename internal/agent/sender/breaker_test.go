@@ -0,0 +1,41 @@
+package sender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Minute)
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, BreakerClosed, b.State())
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	// После OpenTimeout пропускается только один пробный запрос
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	assert.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	// Ошибка пробного запроса снова размыкает цепь
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.State())
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+	b.Success()
+	assert.Equal(t, BreakerClosed, b.State())
+
+	metrics := b.Metrics(map[string]string{"server": "primary"})
+	assert.Equal(t, 0.0, *metrics[0].Value)
+	assert.Equal(t, int64(2), *metrics[1].Delta)
+	assert.Equal(t, "primary", metrics[1].Labels["server"])
+}
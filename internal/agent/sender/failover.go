@@ -0,0 +1,87 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"go-metrics-server/internal/models"
+	"io"
+	"sync"
+)
+
+// Failover отправляет пакеты на текущий сервер, а при ошибке переходит
+// к следующему по списку. Сервер, принявший пакет, становится текущим.
+type Failover struct {
+	senders []MetricsSender
+	mu      sync.Mutex
+	current int
+}
+
+// NewFailover создает Failover; порядок senders задает порядок перебора.
+func NewFailover(senders ...MetricsSender) *Failover {
+	return &Failover{senders: senders}
+}
+
+// Current возвращает индекс текущего сервера.
+func (f *Failover) Current() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.current
+}
+
+func (f *Failover) SendMetricsBatch(ctx context.Context, metrics map[string]interface{}) error {
+	return f.SendBatch(ctx, models.Batch{Metrics: BatchFromMap(metrics)})
+}
+
+// SendBatch перебирает серверы, начиная с текущего, пока один из них
+// не примет пакет. Идентификатор пакета сохраняется для всех попыток.
+// Ошибка соответствует models.ErrBatchRejected, если пакет отклонили все серверы.
+func (f *Failover) SendBatch(ctx context.Context, batch models.Batch) error {
+	if batch.ID == "" {
+		batch.ID = models.NewBatchID()
+	}
+
+	start := f.Current()
+	var errs []error
+	for i := range f.senders {
+		idx := (start + i) % len(f.senders)
+		err := f.senders[idx].SendBatch(ctx, batch)
+		if err == nil {
+			f.mu.Lock()
+			f.current = idx
+			f.mu.Unlock()
+			return nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// Пакет считается отклоненным, только если его отклонили все серверы:
+	// иначе его может принять сервер, который сейчас недоступен
+	rejected := 0
+	for _, err := range errs {
+		if errors.Is(err, models.ErrBatchRejected) {
+			rejected++
+		}
+	}
+	if rejected < len(f.senders) {
+		for i, err := range errs {
+			if errors.Is(err, models.ErrBatchRejected) {
+				errs[i] = errors.New(err.Error())
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close закрывает отправителей, которые держат соединения.
+func (f *Failover) Close() error {
+	var errs []error
+	for _, s := range f.senders {
+		if closer, ok := s.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
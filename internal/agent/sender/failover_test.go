@@ -0,0 +1,69 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubSender запоминает полученные пакеты и возвращает ошибку err.
+type stubSender struct {
+	err     error
+	batches []models.Batch
+}
+
+func (s *stubSender) SendMetricsBatch(ctx context.Context, metrics map[string]interface{}) error {
+	return s.SendBatch(ctx, models.Batch{Metrics: BatchFromMap(metrics)})
+}
+
+func (s *stubSender) SendBatch(_ context.Context, batch models.Batch) error {
+	s.batches = append(s.batches, batch)
+	return s.err
+}
+
+func TestFailover(t *testing.T) {
+	primary := &stubSender{err: errors.New("primary is down")}
+	secondary := &stubSender{}
+	f := NewFailover(primary, secondary)
+	ctx := context.Background()
+
+	assert.NoError(t, f.SendBatch(ctx, testBatch()))
+	assert.Equal(t, 1, f.Current())
+	// Оба сервера получили пакет с одним идентификатором
+	assert.Equal(t, primary.batches[0].ID, secondary.batches[0].ID)
+	assert.NotEmpty(t, secondary.batches[0].ID)
+
+	// Агент остается на рабочем сервере
+	assert.NoError(t, f.SendBatch(ctx, testBatch()))
+	assert.Len(t, primary.batches, 1)
+	assert.Len(t, secondary.batches, 2)
+
+	// Когда отказывает и он, агент возвращается к первому серверу
+	secondary.err = errors.New("secondary is down")
+	primary.err = nil
+	assert.NoError(t, f.SendBatch(ctx, testBatch()))
+	assert.Equal(t, 0, f.Current())
+
+	primary.err = errors.New("primary is down")
+	err := f.SendBatch(ctx, testBatch())
+	assert.ErrorContains(t, err, "primary is down")
+	assert.ErrorContains(t, err, "secondary is down")
+}
+
+func TestFailover_Rejected(t *testing.T) {
+	primary := &stubSender{err: &StatusError{StatusCode: 400, Rejected: true}}
+	secondary := &stubSender{err: errors.New("secondary is down")}
+	f := NewFailover(primary, secondary)
+
+	// Недоступный сервер еще может принять пакет
+	err := f.SendBatch(context.Background(), testBatch())
+	assert.NotErrorIs(t, err, models.ErrBatchRejected)
+	assert.ErrorContains(t, err, "unexpected status: 400")
+
+	secondary.err = &StatusError{StatusCode: 422, Rejected: true}
+	assert.ErrorIs(t, f.SendBatch(context.Background(), testBatch()), models.ErrBatchRejected)
+}
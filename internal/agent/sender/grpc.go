@@ -0,0 +1,113 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"go-metrics-server/internal/models"
+	pb "go-metrics-server/internal/proto"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// GRPCSender отправляет метрики по gRPC: весь пакет передается потоком
+// в рамках одного соединения, которое переиспользуется между отправками.
+type GRPCSender struct {
+	conn       *grpc.ClientConn
+	client     pb.MetricsClient
+	serverAddr string
+	Timeout    time.Duration // Ограничение времени на отправку одного пакета
+	AgentID    string        // Идентификатор агента, передается в метаданных x-agent-id
+	Key        string        // Ключ подписи пакета, подпись передается в метаданных hashsha256
+	RealIP     string        // Адрес для метаданных x-real-ip; если пуст, определяется по маршруту до сервера
+	outbound   outboundAddr
+}
+
+// NewGRPC создает GRPCSender для сервера по адресу serverAddr.
+// Соединение устанавливается при первой отправке.
+func NewGRPC(serverAddr string) (*GRPCSender, error) {
+	serverAddr = strings.TrimPrefix(strings.TrimPrefix(serverAddr, httpScheme), httpsScheme)
+
+	conn, err := grpc.NewClient(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
+	}
+	return &GRPCSender{
+		conn:       conn,
+		client:     pb.NewMetricsClient(conn),
+		serverAddr: serverAddr,
+		Timeout:    10 * time.Second,
+	}, nil
+}
+
+func (s *GRPCSender) SendMetricsBatch(ctx context.Context, metrics map[string]interface{}) error {
+	return s.SendBatch(ctx, models.Batch{Metrics: BatchFromMap(metrics)})
+}
+
+// SendBatch отправляет пакет метрик одним потоком UpdateMetrics.
+// Идентификатор пакета передается в метаданных idempotency-key,
+// подпись пакета при заданном ключе — в метаданных hashsha256.
+func (s *GRPCSender) SendBatch(ctx context.Context, batch models.Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	if batch.ID == "" {
+		batch.ID = models.NewBatchID()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	if s.AgentID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(models.AgentIDHeader), s.AgentID)
+	}
+	realIP := s.RealIP
+	if realIP == "" {
+		realIP = s.outbound.get(s.serverAddr)
+	}
+	if realIP != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(realIPHeader), realIP)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(models.IdempotencyKeyHeader), batch.ID)
+
+	// Подпись передается в метаданных до сообщений, поэтому считается заранее
+	messages := make([]*pb.Metric, len(batch.Metrics))
+	for i, metric := range batch.Metrics {
+		messages[i] = pb.FromModel(metric)
+	}
+	if s.Key != "" {
+		signer := pb.NewSigner(s.Key)
+		for _, msg := range messages {
+			if err := signer.Add(msg); err != nil {
+				return fmt.Errorf("failed to sign metric %s: %w", msg.GetId(), err)
+			}
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, pb.HashMetadata, signer.Sum())
+	}
+
+	stream, err := s.client.UpdateMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	for _, msg := range messages {
+		if err := stream.Send(msg); err != nil {
+			return fmt.Errorf("failed to send metric %s: %w", msg.GetId(), err)
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	if int(resp.GetAccepted()) != len(batch.Metrics) {
+		return fmt.Errorf("server accepted %d of %d metrics", resp.GetAccepted(), len(batch.Metrics))
+	}
+	return nil
+}
+
+// Close закрывает соединение с сервером.
+func (s *GRPCSender) Close() error {
+	return s.conn.Close()
+}
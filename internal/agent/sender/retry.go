@@ -0,0 +1,125 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/models"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy задает повторы отправки с экспоненциальной задержкой.
+type RetryPolicy struct {
+	MaxAttempts  int           // Число попыток, включая первую
+	InitialDelay time.Duration // Задержка перед первым повтором
+	MaxDelay     time.Duration // Верхняя граница задержки
+	Multiplier   float64       // Множитель задержки для каждого следующего повтора
+	Jitter       float64       // Доля случайного отклонения задержки, от 0 до 1
+}
+
+// DefaultRetryPolicy — политика повторов по умолчанию.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  4,
+	InitialDelay: time.Second,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+// backoff возвращает задержку перед повтором номер retry (начиная с 1).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(retry-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	// Случайное отклонение разводит повторы агентов, потерявших сервер одновременно
+	delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+// StatusError — ответ сервера с неуспешным статусом.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // Задержка из заголовка Retry-After, если он был
+	Rejected   bool          // Сервер отметил пакет заголовком X-Batch-Rejected
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %d", e.StatusCode)
+}
+
+// Temporary сообщает, имеет ли смысл повторить запрос: при ошибках сервера
+// и превышении лимита запросов.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Is сопоставляет с models.ErrBatchRejected только ответы 400 и 422,
+// которые сервер явно отметил как отказ из-за содержимого пакета. Прочие
+// ошибки запроса (401, 403, неверная подпись или шифрование) зависят
+// от настроек, а не от пакета, и пакет остается в очереди.
+func (e *StatusError) Is(target error) bool {
+	return target == models.ErrBatchRejected && e.Rejected &&
+		(e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity)
+}
+
+// parseRetryAfter разбирает заголовок Retry-After: число секунд или дату.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// isRetryable сообщает, является ли ошибка временной: сетевые ошибки,
+// разрыв соединения, 5xx и 429. Ошибки запроса, подписи и отмена контекста
+// не повторяются.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrInvalidResponseHash) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// retryDelay возвращает задержку перед повтором: не меньше Retry-After,
+// если сервер его указал.
+func (p RetryPolicy) retryDelay(retry int, err error) time.Duration {
+	delay := p.backoff(retry)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+		delay = statusErr.RetryAfter
+	}
+	return delay
+}
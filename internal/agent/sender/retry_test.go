@@ -0,0 +1,207 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"go-metrics-server/internal/agent/outbox"
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetry — политика повторов без длительных задержек для тестов.
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 2}
+
+func testBatch() models.Batch {
+	value := 1.0
+	return models.Batch{Metrics: []models.Metrics{{ID: "Alloc", MType: "gauge", Value: &value}}}
+}
+
+func TestSendBatch_RetriesTemporaryErrors(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	s := New(ts.URL, "")
+	s.Retry = fastRetry
+	assert.NoError(t, s.SendBatch(context.Background(), testBatch()))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestSendBatch_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	s := New(ts.URL, "")
+	s.Retry = fastRetry
+	err := s.SendBatch(context.Background(), testBatch())
+
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, BreakerClosed, s.Breaker.State())
+}
+
+func TestSendBatch_RetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	s := New(ts.URL, "")
+	s.Retry = fastRetry
+	start := time.Now()
+	assert.NoError(t, s.SendBatch(context.Background(), testBatch()))
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestSendBatch_ContextCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	s := New(ts.URL, "")
+	s.Retry = RetryPolicy{MaxAttempts: 5, InitialDelay: time.Hour, Multiplier: 1}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := s.SendBatch(ctx, testBatch())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSendBatch_CircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	s := New(ts.URL, "")
+	s.Retry = fastRetry
+	s.Breaker = NewCircuitBreaker(2, time.Hour)
+
+	err := s.SendBatch(context.Background(), testBatch())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// Пока цепь разомкнута, сервер не опрашивается
+	err = s.SendBatch(context.Background(), testBatch())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, BreakerOpen, s.Breaker.State())
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{fmt.Errorf("failed to send request: %w", syscall.ECONNRESET), true},
+		{&StatusError{StatusCode: http.StatusInternalServerError}, true},
+		{&StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{&StatusError{StatusCode: http.StatusNotFound}, false},
+		{ErrInvalidResponseHash, false},
+		{context.Canceled, false},
+		{errors.New("failed to marshal metrics"), false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isRetryable(tt.err), tt.err.Error())
+	}
+}
+
+func TestStatusError_Rejected(t *testing.T) {
+	assert.ErrorIs(t, fmt.Errorf("send: %w", &StatusError{StatusCode: http.StatusBadRequest, Rejected: true}), models.ErrBatchRejected)
+	assert.ErrorIs(t, &StatusError{StatusCode: http.StatusUnprocessableEntity, Rejected: true}, models.ErrBatchRejected)
+	// Без отметки сервера 400 может означать неверную подпись или шифрование
+	assert.NotErrorIs(t, &StatusError{StatusCode: http.StatusBadRequest}, models.ErrBatchRejected)
+	assert.NotErrorIs(t, &StatusError{StatusCode: http.StatusForbidden, Rejected: true}, models.ErrBatchRejected)
+	assert.NotErrorIs(t, &StatusError{StatusCode: http.StatusUnauthorized}, models.ErrBatchRejected)
+	assert.NotErrorIs(t, &StatusError{StatusCode: http.StatusTooManyRequests}, models.ErrBatchRejected)
+	assert.NotErrorIs(t, &StatusError{StatusCode: http.StatusBadGateway}, models.ErrBatchRejected)
+}
+
+func TestSendBatch_OutboxKeepsBatch(t *testing.T) {
+	var status atomic.Int32
+	var rejected atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rejected.Load() {
+			w.Header().Set(models.BatchRejectedHeader, "true")
+		}
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer ts.Close()
+
+	s := New(ts.URL, "")
+	s.Retry = fastRetry
+	queue, err := outbox.Open(t.TempDir(), 10)
+	require.NoError(t, err)
+	require.NoError(t, queue.Push(testBatch().Metrics))
+	send := func(batch models.Batch) error { return s.SendBatch(context.Background(), batch) }
+
+	// Адрес агента вне доверенной подсети: пакет остается в очереди
+	status.Store(http.StatusForbidden)
+	assert.Error(t, queue.Drain(send))
+	assert.Equal(t, 1, queue.Len())
+
+	// Неверная подпись или шифрование: 400 без отметки сервера
+	status.Store(http.StatusBadRequest)
+	assert.Error(t, queue.Drain(send))
+	assert.Equal(t, 1, queue.Len())
+
+	// Отказ из-за содержимого пакета: пакет удаляется
+	rejected.Store(true)
+	assert.ErrorIs(t, queue.Drain(send), models.ErrBatchRejected)
+	assert.Equal(t, 0, queue.Len())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		delay := p.backoff(2)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 300*time.Millisecond)
+	}
+	// Задержка ограничена MaxDelay до применения отклонения
+	assert.LessOrEqual(t, p.backoff(20), 1500*time.Millisecond)
+}
@@ -3,37 +3,55 @@ package sender
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/hmac"
+	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-metrics-server/internal/encryption"
 	"go-metrics-server/internal/models"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	httpScheme        = "http://"
-	httpsScheme       = "https://"
-	maxRetries        = 3
-	initialRetryDelay = time.Second
-	secondRetryDelay  = 3 * time.Second
-	thirdRetryDelay   = 5 * time.Second
+	httpScheme  = "http://"
+	httpsScheme = "https://"
 )
 
-var retryableErrors = []error{
-	errors.New("connection refused"),
-	errors.New("connection reset by peer"),
-	errors.New("i/o timeout"),
+// realIPHeader — заголовок с адресом агента для проверки доверенной подсети
+// на сервере (middleware.TrustedSubnetMiddleware).
+const realIPHeader = "X-Real-IP"
+
+// ErrInvalidResponseHash возвращается, если подпись ответа сервера отсутствует или не совпадает.
+var ErrInvalidResponseHash = errors.New("invalid response HashSHA256")
+
+// MetricsSender отправляет метрики агента на сервер. Отправка прерывается
+// при отмене контекста.
+type MetricsSender interface {
+	SendMetricsBatch(ctx context.Context, metrics map[string]interface{}) error
+	SendBatch(ctx context.Context, batch models.Batch) error
 }
 
+// Sender отправляет метрики по HTTP.
 type Sender struct {
 	ServerURL string
 	Client    *http.Client
 	Key       string
+	AgentID   string          // Идентификатор агента, передается в заголовке X-Agent-ID
+	PublicKey *rsa.PublicKey  // Публичный ключ сервера для шифрования тела запросов
+	RealIP    string          // Адрес для заголовка X-Real-IP; если пуст, определяется по маршруту до сервера
+	Retry     RetryPolicy     // Политика повторов при временных ошибках
+	Breaker   *CircuitBreaker // Автомат защиты от обращений к недоступному серверу; nil отключает его
+	outbound  outboundAddr
 }
 
 func New(serverURL, key string) *Sender {
@@ -44,18 +62,40 @@ func New(serverURL, key string) *Sender {
 		ServerURL: serverURL,
 		Client:    &http.Client{Timeout: 10 * time.Second},
 		Key:       key,
+		Retry:     DefaultRetryPolicy,
+		Breaker:   NewCircuitBreaker(5, 30*time.Second),
 	}
 }
 
-func (s *Sender) SendMetric(metricType, name string, value interface{}) error {
+func (s *Sender) SendMetric(ctx context.Context, metricType, name string, value interface{}) error {
 	metric, err := s.createMetric(metricType, name, value)
 	if err != nil {
 		return err
 	}
-	return s.sendWithRetry("/update/", []models.Metrics{metric})
+	return s.sendWithRetry(ctx, "/update/", []models.Metrics{metric}, "")
 }
 
-func (s *Sender) SendMetricsBatch(metrics map[string]interface{}) error {
+func (s *Sender) SendMetricsBatch(ctx context.Context, metrics map[string]interface{}) error {
+	return s.SendBatch(ctx, models.Batch{Metrics: BatchFromMap(metrics)})
+}
+
+// SendBatch отправляет пакет метрик на /updates/. Идентификатор пакета
+// передается в заголовке Idempotency-Key, чтобы повтор не был применен дважды;
+// если он не задан, генерируется новый.
+func (s *Sender) SendBatch(ctx context.Context, batch models.Batch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	if batch.ID == "" {
+		batch.ID = models.NewBatchID()
+	}
+
+	return s.sendWithRetry(ctx, "/updates/", batch.Metrics, batch.ID)
+}
+
+// BatchFromMap преобразует снимок метрик агента в пакет для отправки.
+// Значения неподдерживаемых типов пропускаются.
+func BatchFromMap(metrics map[string]interface{}) []models.Metrics {
 	var batch []models.Metrics
 
 	for name, value := range metrics {
@@ -69,17 +109,19 @@ func (s *Sender) SendMetricsBatch(metrics map[string]interface{}) error {
 		case int64:
 			metric.MType = "counter"
 			metric.Delta = &v
+		case models.Histogram:
+			metric.MType = "histogram"
+			metric.Histogram = &v
+		case models.Summary:
+			metric.MType = "summary"
+			metric.Summary = &v
 		default:
 			continue
 		}
 		batch = append(batch, metric)
 	}
 
-	if len(batch) == 0 {
-		return nil
-	}
-
-	return s.sendRequest("/updates/", batch)
+	return batch
 }
 
 func (s *Sender) createMetric(metricType, name string, value interface{}) (models.Metrics, error) {
@@ -101,6 +143,18 @@ func (s *Sender) createMetric(metricType, name string, value interface{}) (model
 		} else {
 			return models.Metrics{}, fmt.Errorf("invalid value type for counter metric, expected int64, got %T", value)
 		}
+	case "histogram":
+		if v, ok := value.(models.Histogram); ok {
+			metric.Histogram = &v
+		} else {
+			return models.Metrics{}, fmt.Errorf("invalid value type for histogram metric, expected models.Histogram, got %T", value)
+		}
+	case "summary":
+		if v, ok := value.(models.Summary); ok {
+			metric.Summary = &v
+		} else {
+			return models.Metrics{}, fmt.Errorf("invalid value type for summary metric, expected models.Summary, got %T", value)
+		}
 	default:
 		return models.Metrics{}, fmt.Errorf("unknown metric type: %s", metricType)
 	}
@@ -108,40 +162,67 @@ func (s *Sender) createMetric(metricType, name string, value interface{}) (model
 	return metric, nil
 }
 
-func (s *Sender) sendWithRetry(endpoint string, metrics []models.Metrics) error {
+// sendWithRetry отправляет запрос, повторяя его при временных ошибках
+// согласно s.Retry. Повтор с тем же ключом идемпотентности безопасен.
+func (s *Sender) sendWithRetry(ctx context.Context, endpoint string, metrics []models.Metrics, idempotencyKey string) error {
+	attempts := max(s.Retry.MaxAttempts, 1)
+
 	var lastErr error
-	delays := []time.Duration{initialRetryDelay, secondRetryDelay, thirdRetryDelay}
+	attempt := 1
+	for ; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			timer := time.NewTimer(s.Retry.retryDelay(attempt-1, lastErr))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("retry interrupted: %w, last error: %w", ctx.Err(), lastErr)
+			case <-timer.C:
+			}
+		}
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			time.Sleep(delays[attempt-1])
+		if s.Breaker != nil {
+			if err := s.Breaker.Allow(); err != nil {
+				if lastErr != nil {
+					return fmt.Errorf("%w, last error: %w", err, lastErr)
+				}
+				return err
+			}
 		}
 
-		err := s.sendRequest(endpoint, metrics)
+		err := s.sendRequest(ctx, endpoint, metrics, idempotencyKey)
+		s.recordResult(ctx, err)
 		if err == nil {
 			return nil
 		}
 
 		lastErr = err
-		if !s.isRetryableError(err) {
+		if !isRetryable(err) || ctx.Err() != nil {
 			break
 		}
 	}
 
-	return fmt.Errorf("after %d attempts, last error: %w", maxRetries+1, lastErr)
+	return fmt.Errorf("after %d attempts, last error: %w", min(attempt, attempts), lastErr)
 }
 
-func (s *Sender) isRetryableError(err error) bool {
-	errStr := err.Error()
-	for _, retryableErr := range retryableErrors {
-		if strings.Contains(errStr, retryableErr.Error()) {
-			return true
-		}
+// recordResult передает результат запроса автомату защиты. Отказом сервера
+// считаются только временные ошибки: ответ 4xx означает, что сервер доступен.
+func (s *Sender) recordResult(ctx context.Context, err error) {
+	if s.Breaker == nil {
+		return
+	}
+	switch {
+	case err == nil:
+		s.Breaker.Success()
+	case ctx.Err() != nil:
+		s.Breaker.Abort()
+	case isRetryable(err):
+		s.Breaker.Failure()
+	default:
+		s.Breaker.Success()
 	}
-	return false
 }
 
-func (s *Sender) sendRequest(endpoint string, metrics []models.Metrics) error {
+func (s *Sender) sendRequest(ctx context.Context, endpoint string, metrics []models.Metrics, idempotencyKey string) error {
 	jsonData, err := json.Marshal(metrics)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metrics: %w", err)
@@ -156,10 +237,20 @@ func (s *Sender) sendRequest(endpoint string, metrics []models.Metrics) error {
 		return fmt.Errorf("compression close error: %w", err)
 	}
 
-	req, err := http.NewRequest(
+	// Шифруется уже сжатое тело: подпись и сжатие остаются под шифрованием
+	body := buf.Bytes()
+	if s.PublicKey != nil {
+		body, err = encryption.Encrypt(s.PublicKey, body)
+		if err != nil {
+			return fmt.Errorf("encryption error: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
 		fmt.Sprintf("%s%s", s.ServerURL, endpoint),
-		&buf,
+		bytes.NewReader(body),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -168,12 +259,21 @@ func (s *Sender) sendRequest(endpoint string, metrics []models.Metrics) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("Accept-Encoding", "gzip")
+	if s.PublicKey != nil {
+		req.Header.Set(encryption.Header, encryption.Scheme)
+	}
+	if s.AgentID != "" {
+		req.Header.Set(models.AgentIDHeader, s.AgentID)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(models.IdempotencyKeyHeader, idempotencyKey)
+	}
+	if ip := s.realIP(req.URL); ip != "" {
+		req.Header.Set(realIPHeader, ip)
+	}
 
 	if s.Key != "" {
-		h := hmac.New(sha256.New, []byte(s.Key))
-		h.Write(jsonData)
-		hash := hex.EncodeToString(h.Sum(nil))
-		req.Header.Set("HashSHA256", hash)
+		req.Header.Set("HashSHA256", s.sign(jsonData))
 	}
 
 	resp, err := s.Client.Do(req)
@@ -183,8 +283,104 @@ func (s *Sender) sendRequest(endpoint string, metrics []models.Metrics) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Rejected:   resp.Header.Get(models.BatchRejectedHeader) != "",
+		}
+	}
+
+	if s.Key != "" {
+		return s.verifyResponse(resp)
 	}
 
 	return nil
 }
+
+// realIP возвращает адрес агента для заголовка X-Real-IP.
+func (s *Sender) realIP(u *url.URL) string {
+	if s.RealIP != "" {
+		return s.RealIP
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return s.outbound.get(net.JoinHostPort(u.Hostname(), port))
+}
+
+// outboundAddr запоминает адрес, определенный outboundIP, чтобы не выбирать
+// маршрут при каждой отправке. Неудачное определение не запоминается
+// и повторяется при следующей отправке.
+type outboundAddr struct {
+	mu sync.Mutex
+	ip string
+}
+
+func (a *outboundAddr) get(addr string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ip == "" {
+		a.ip = outboundIP(addr)
+	}
+	return a.ip
+}
+
+// outboundIP возвращает адрес локального интерфейса, через который идет
+// маршрут до addr. UDP-сокет только выбирает маршрут, пакеты не отправляются.
+func outboundIP(addr string) string {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	if udpAddr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return udpAddr.IP.String()
+	}
+	return ""
+}
+
+// verifyResponse проверяет подпись ответа сервера. Подпись считается
+// по несжатому телу, поэтому сжатый ответ предварительно распаковывается.
+func (s *Sender) verifyResponse(resp *http.Response) error {
+	hash := resp.Header.Get("HashSHA256")
+	if hash == "" {
+		return fmt.Errorf("%w: header is missing", ErrInvalidResponseHash)
+	}
+
+	var reader io.Reader = resp.Body
+	if strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress response: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	received, err := hex.DecodeString(hash)
+	if err != nil || !hmac.Equal(received, s.mac(body)) {
+		return ErrInvalidResponseHash
+	}
+	return nil
+}
+
+// sign возвращает подпись данных в шестнадцатеричном виде.
+func (s *Sender) sign(data []byte) string {
+	return hex.EncodeToString(s.mac(data))
+}
+
+func (s *Sender) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, []byte(s.Key))
+	h.Write(data)
+	return h.Sum(nil)
+}
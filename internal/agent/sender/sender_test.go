@@ -1,11 +1,20 @@
 package sender
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"go-metrics-server/internal/models"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSender_SendMetric(t *testing.T) {
@@ -15,7 +24,7 @@ func TestSender_SendMetric(t *testing.T) {
 	defer ts.Close()
 
 	s := New(ts.URL, "")
-	err := s.SendMetric("gauge", "test", 123.45)
+	err := s.SendMetric(context.Background(), "gauge", "test", 123.45)
 	assert.NoError(t, err)
 
 	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -24,7 +33,8 @@ func TestSender_SendMetric(t *testing.T) {
 	defer ts.Close()
 
 	s = New(ts.URL, "")
-	err = s.SendMetric("gauge", "test", 123.45)
+	s.Retry = fastRetry
+	err = s.SendMetric(context.Background(), "gauge", "test", 123.45)
 	assert.Error(t, err)
 }
 
@@ -39,12 +49,12 @@ func TestSendMetricJSON(t *testing.T) {
 	s := New(ts.URL, "")
 
 	t.Run("send gauge", func(t *testing.T) {
-		err := s.SendMetric("gauge", "test", 1.23)
+		err := s.SendMetric(context.Background(), "gauge", "test", 1.23)
 		assert.NoError(t, err)
 	})
 
 	t.Run("send counter", func(t *testing.T) {
-		err := s.SendMetric("counter", "test", int64(10))
+		err := s.SendMetric(context.Background(), "counter", "test", int64(10))
 		assert.NoError(t, err)
 	})
 }
@@ -53,21 +63,147 @@ func TestHashHeader(t *testing.T) {
 	var receivedHash string
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		receivedHash = r.Header.Get("HashSHA256")
+		// Ответ подписывается тем же ключом, иначе агент его отвергнет
+		h := hmac.New(sha256.New, []byte("testkey"))
+		w.Header().Set("HashSHA256", hex.EncodeToString(h.Sum(nil)))
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer ts.Close()
 
 	t.Run("without key", func(t *testing.T) {
 		s := New(ts.URL, "")
-		err := s.SendMetric("gauge", "test", 1.23)
+		err := s.SendMetric(context.Background(), "gauge", "test", 1.23)
 		assert.NoError(t, err)
 		assert.Empty(t, receivedHash)
 	})
 
 	t.Run("with key", func(t *testing.T) {
 		s := New(ts.URL, "testkey")
-		err := s.SendMetric("gauge", "test", 1.23)
+		err := s.SendMetric(context.Background(), "gauge", "test", 1.23)
 		assert.NoError(t, err)
 		assert.NotEmpty(t, receivedHash)
 	})
 }
+
+func TestResponseHashVerification(t *testing.T) {
+	const key = "testkey"
+	body := []byte(`[{"id":"Alloc","type":"gauge","value":1}]`)
+
+	newServer := func(hash string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hash != "" {
+				w.Header().Set("HashSHA256", hash)
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusOK)
+			gz := gzip.NewWriter(w)
+			gz.Write(body)
+			gz.Close()
+		}))
+	}
+
+	t.Run("valid signature of compressed response", func(t *testing.T) {
+		h := hmac.New(sha256.New, []byte(key))
+		h.Write(body)
+		ts := newServer(hex.EncodeToString(h.Sum(nil)))
+		defer ts.Close()
+
+		err := New(ts.URL, key).SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		ts := newServer("")
+		defer ts.Close()
+
+		err := New(ts.URL, key).SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+		assert.ErrorIs(t, err, ErrInvalidResponseHash)
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		h := hmac.New(sha256.New, []byte("other"))
+		h.Write(body)
+		ts := newServer(hex.EncodeToString(h.Sum(nil)))
+		defer ts.Close()
+
+		err := New(ts.URL, key).SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+		assert.ErrorIs(t, err, ErrInvalidResponseHash)
+	})
+}
+
+func TestAgentIDHeader(t *testing.T) {
+	var receivedID, receivedIP string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedID = r.Header.Get(models.AgentIDHeader)
+		receivedIP = r.Header.Get(realIPHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	s := New(ts.URL, "")
+	s.AgentID = "host-1"
+	err := s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, "host-1", receivedID)
+	// Маршрут до тестового сервера идет через loopback
+	assert.Equal(t, "127.0.0.1", receivedIP)
+
+	s.RealIP = "10.0.0.5"
+	err = s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", receivedIP)
+}
+
+func TestOutboundAddr(t *testing.T) {
+	var a outboundAddr
+	// Неудачное определение адреса не запоминается
+	assert.Empty(t, a.get("invalid address"))
+	assert.Equal(t, "127.0.0.1", a.get("127.0.0.1:8080"))
+	// Определенный адрес используется в следующих отправках без выбора маршрута
+	assert.Equal(t, "127.0.0.1", a.get("invalid address"))
+}
+
+func TestIdempotencyKeyHeader(t *testing.T) {
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(models.IdempotencyKeyHeader))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	s := New(ts.URL, "")
+	batch := []models.Metrics{{ID: "PollCount", MType: "counter", Delta: new(int64)}}
+	assert.NoError(t, s.SendBatch(context.Background(), models.Batch{ID: "batch-1", Metrics: batch}))
+	assert.NoError(t, s.SendBatch(context.Background(), models.Batch{Metrics: batch}))
+	assert.NoError(t, s.SendBatch(context.Background(), models.Batch{Metrics: batch}))
+
+	// Без идентификатора пакета ключ генерируется для каждой отправки
+	require.Len(t, keys, 3)
+	assert.Equal(t, "batch-1", keys[0])
+	assert.NotEmpty(t, keys[1])
+	assert.NotEqual(t, keys[1], keys[2])
+}
+
+func TestSendMetricsBatch_Histogram(t *testing.T) {
+	var received []models.Metrics
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz, err := gzip.NewReader(r.Body)
+		if assert.NoError(t, err) {
+			json.NewDecoder(gz).Decode(&received)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	h := models.NewHistogram([]float64{1})
+	h.Observe(0.5)
+
+	s := New(ts.URL, "")
+	err := s.SendMetricsBatch(context.Background(), map[string]interface{}{"latency": *h})
+	assert.NoError(t, err)
+
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, "histogram", received[0].MType)
+		assert.Equal(t, []uint64{1, 0}, received[0].Histogram.Counts)
+	}
+}
@@ -0,0 +1,214 @@
+package statsd
+
+import (
+	"fmt"
+	"go-metrics-server/internal/models"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	// TimersHistogram — таймеры отправляются гистограммой с границами TimerBuckets.
+	TimersHistogram = "histogram"
+	// TimersSummary — таймеры отправляются сводкой с квантилями TimerQuantiles.
+	TimersSummary = "summary"
+)
+
+// TimerBuckets — границы корзин гистограммы таймеров в миллисекундах.
+var TimerBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// TimerQuantiles — квантили сводки таймеров; 0 и 1 — минимум и максимум.
+var TimerQuantiles = []float64{0, 0.5, 0.9, 0.99, 1}
+
+// maxTimerSamples ограничивает число значений таймера, хранимых
+// для вычисления квантилей за один интервал.
+const maxTimerSamples = 10000
+
+type series struct {
+	name   string
+	labels map[string]string
+}
+
+type counterState struct {
+	series
+	value float64 // Накопленное значение с учетом частоты выборки
+}
+
+type gaugeState struct {
+	series
+	value   float64
+	updated bool // Значение изменилось с последней выгрузки
+}
+
+type timerState struct {
+	series
+	histogram *models.Histogram
+	values    []float64
+	sum       float64
+	count     uint64
+}
+
+// Buffer накапливает значения StatsD за интервал отправки.
+type Buffer struct {
+	mu       sync.Mutex
+	timers   string
+	counters map[string]*counterState
+	gauges   map[string]*gaugeState
+	samples  map[string]*timerState
+	restored []models.Metrics // Метрики неудавшейся выгрузки
+}
+
+// NewBuffer создает буфер; timers — TimersHistogram или TimersSummary.
+func NewBuffer(timers string) (*Buffer, error) {
+	if timers != TimersHistogram && timers != TimersSummary {
+		return nil, fmt.Errorf("unknown statsd timers mode %q", timers)
+	}
+	return &Buffer{
+		timers:   timers,
+		counters: make(map[string]*counterState),
+		gauges:   make(map[string]*gaugeState),
+		samples:  make(map[string]*timerState),
+	}, nil
+}
+
+// Add учитывает значение в текущем интервале. Значение счетчика и число
+// наблюдений таймера масштабируются на 1/Rate.
+func (b *Buffer) Add(s Sample) {
+	key := s.Type + "\x00" + s.Name + "\x00" + models.LabelsKey(s.Tags)
+	sr := series{name: s.Name, labels: s.Tags}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch s.Type {
+	case TypeCounter:
+		c, ok := b.counters[key]
+		if !ok {
+			c = &counterState{series: sr}
+			b.counters[key] = c
+		}
+		c.value += s.Value / s.Rate
+	case TypeGauge:
+		g, ok := b.gauges[key]
+		if !ok {
+			g = &gaugeState{series: sr}
+			b.gauges[key] = g
+		}
+		if s.Relative {
+			g.value += s.Value
+		} else {
+			g.value = s.Value
+		}
+		g.updated = true
+	case TypeTimer, TypeHistogram:
+		// Таймер и гистограмма StatsD отправляются одним рядом
+		key = TypeTimer + "\x00" + s.Name + "\x00" + models.LabelsKey(s.Tags)
+		t, ok := b.samples[key]
+		if !ok {
+			t = &timerState{series: sr}
+			if b.timers == TimersHistogram {
+				t.histogram = models.NewHistogram(TimerBuckets)
+			}
+			b.samples[key] = t
+		}
+		n := uint64(max(math.Round(1/s.Rate), 1))
+		if t.histogram != nil {
+			t.histogram.ObserveN(s.Value, n)
+		} else if len(t.values) < maxTimerSamples {
+			t.values = append(t.values, s.Value)
+		}
+		t.sum += s.Value * float64(n)
+		t.count += n
+	}
+}
+
+// Flush возвращает метрики, накопленные с прошлой выгрузки, и начинает
+// новый интервал. Дробный остаток счетчиков переносится в следующий интервал,
+// gauge попадают в результат, только если обновлялись.
+func (b *Buffer) Flush() []models.Metrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var result []models.Metrics
+	for _, key := range sortedKeys(b.counters) {
+		c := b.counters[key]
+		delta := int64(math.Round(c.value))
+		c.value -= float64(delta)
+		if math.Abs(c.value) < 1e-9 {
+			delete(b.counters, key)
+		}
+		if delta != 0 {
+			result = append(result, models.Metrics{ID: c.name, MType: "counter", Delta: &delta, Labels: c.labels})
+		}
+	}
+	for _, key := range sortedKeys(b.gauges) {
+		g := b.gauges[key]
+		if !g.updated {
+			continue
+		}
+		g.updated = false
+		value := g.value
+		result = append(result, models.Metrics{ID: g.name, MType: "gauge", Value: &value, Labels: g.labels})
+	}
+	for _, key := range sortedKeys(b.samples) {
+		result = append(result, b.samples[key].metric())
+	}
+	b.samples = make(map[string]*timerState)
+
+	if b.restored != nil {
+		result = models.MergeMetrics(b.restored, result)
+		b.restored = nil
+	}
+	return result
+}
+
+// Restore возвращает в буфер результат Flush, который не удалось отправить:
+// он будет объединен со следующей выгрузкой.
+func (b *Buffer) Restore(metrics []models.Metrics) {
+	if len(metrics) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.restored = models.MergeMetrics(metrics, b.restored)
+}
+
+func (t *timerState) metric() models.Metrics {
+	if t.histogram != nil {
+		return models.Metrics{ID: t.name, MType: "histogram", Histogram: t.histogram, Labels: t.labels}
+	}
+
+	sort.Float64s(t.values)
+	quantiles := make([]models.Quantile, 0, len(TimerQuantiles))
+	for _, q := range TimerQuantiles {
+		quantiles = append(quantiles, models.Quantile{Quantile: q, Value: quantile(t.values, q)})
+	}
+	return models.Metrics{
+		ID:      t.name,
+		MType:   "summary",
+		Labels:  t.labels,
+		Summary: &models.Summary{Quantiles: quantiles, Sum: t.sum, Count: t.count},
+	}
+}
+
+// quantile возвращает q-квантиль отсортированных значений
+// с линейной интерполяцией между соседними значениями.
+func quantile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+package statsd
+
+import (
+	"testing"
+
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findMetric(metrics []models.Metrics, mType, id string) *models.Metrics {
+	for i := range metrics {
+		if metrics[i].MType == mType && metrics[i].ID == id {
+			return &metrics[i]
+		}
+	}
+	return nil
+}
+
+func mustAdd(t *testing.T, b *Buffer, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		s, err := Parse(line)
+		require.NoError(t, err)
+		b.Add(s)
+	}
+}
+
+func TestNewBuffer_InvalidMode(t *testing.T) {
+	_, err := NewBuffer("median")
+	assert.Error(t, err)
+}
+
+func TestBuffer_Counters(t *testing.T) {
+	b, err := NewBuffer(TimersHistogram)
+	require.NoError(t, err)
+
+	mustAdd(t, b, "hits:1|c", "hits:2|c", "hits:1|c|@0.1", "hits:1|c|#env:prod")
+	metrics := b.Flush()
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "hits", metrics[0].ID)
+	assert.Equal(t, int64(13), *metrics[0].Delta)
+	assert.Equal(t, map[string]string{"env": "prod"}, metrics[1].Labels)
+	assert.Equal(t, int64(1), *metrics[1].Delta)
+
+	// Счетчики передаются дельтами за интервал
+	assert.Empty(t, b.Flush())
+
+	// Дробный остаток переносится в следующий интервал
+	mustAdd(t, b, "hits:1|c|@0.4")
+	assert.Equal(t, int64(3), *b.Flush()[0].Delta)
+	mustAdd(t, b, "hits:1|c|@0.4")
+	assert.Equal(t, int64(2), *b.Flush()[0].Delta)
+}
+
+func TestBuffer_Gauges(t *testing.T) {
+	b, err := NewBuffer(TimersHistogram)
+	require.NoError(t, err)
+
+	mustAdd(t, b, "temp:10|g", "temp:12.5|g")
+	metrics := b.Flush()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 12.5, *metrics[0].Value)
+
+	// Необновленный gauge не отправляется, относительное изменение
+	// применяется к последнему значению
+	assert.Empty(t, b.Flush())
+	mustAdd(t, b, "temp:+2|g", "temp:-0.5|g")
+	assert.Equal(t, 14.0, *b.Flush()[0].Value)
+}
+
+func TestBuffer_TimersHistogram(t *testing.T) {
+	b, err := NewBuffer(TimersHistogram)
+	require.NoError(t, err)
+
+	mustAdd(t, b, "latency:3|ms", "latency:320|ms", "latency:320|ms|@0.5", "latency:20000|h")
+	metrics := b.Flush()
+	require.Len(t, metrics, 1)
+	h := metrics[0].Histogram
+	require.NotNil(t, h)
+	assert.Equal(t, "histogram", metrics[0].MType)
+	assert.Equal(t, TimerBuckets, h.Bounds)
+	assert.Equal(t, uint64(5), h.Count)
+	assert.Equal(t, 3+320*3+20000.0, h.Sum)
+	assert.Equal(t, uint64(1), h.Counts[0])
+	assert.Equal(t, uint64(3), h.Counts[6])
+	assert.Equal(t, uint64(1), h.Counts[len(h.Counts)-1])
+	assert.NoError(t, h.Validate())
+
+	assert.Empty(t, b.Flush())
+}
+
+func TestBuffer_TimersSummary(t *testing.T) {
+	b, err := NewBuffer(TimersSummary)
+	require.NoError(t, err)
+
+	for _, line := range []string{"latency:10|ms", "latency:20|ms", "latency:30|ms", "latency:40|ms", "latency:50|ms|@0.5"} {
+		mustAdd(t, b, line)
+	}
+	metrics := b.Flush()
+	require.Len(t, metrics, 1)
+	s := metrics[0].Summary
+	require.NotNil(t, s)
+	assert.Equal(t, "summary", metrics[0].MType)
+	assert.Equal(t, uint64(6), s.Count)
+	assert.Equal(t, 200.0, s.Sum)
+	require.Len(t, s.Quantiles, len(TimerQuantiles))
+	assert.Equal(t, models.Quantile{Quantile: 0, Value: 10}, s.Quantiles[0])
+	assert.Equal(t, models.Quantile{Quantile: 0.5, Value: 30}, s.Quantiles[1])
+	assert.Equal(t, models.Quantile{Quantile: 1, Value: 50}, s.Quantiles[4])
+}
+
+func TestBuffer_Restore(t *testing.T) {
+	b, err := NewBuffer(TimersHistogram)
+	require.NoError(t, err)
+
+	mustAdd(t, b, "hits:2|c", "temp:1|g", "latency:3|ms")
+	b.Restore(b.Flush())
+
+	mustAdd(t, b, "hits:3|c", "temp:2|g", "latency:4|ms")
+	metrics := b.Flush()
+	require.Len(t, metrics, 3)
+	assert.Equal(t, int64(5), *findMetric(metrics, "counter", "hits").Delta)
+	assert.Equal(t, 2.0, *findMetric(metrics, "gauge", "temp").Value)
+	assert.Equal(t, uint64(2), findMetric(metrics, "histogram", "latency").Histogram.Count)
+
+	assert.Empty(t, b.Flush())
+}
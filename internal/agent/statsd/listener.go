@@ -0,0 +1,85 @@
+package statsd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/models"
+	"net"
+	"sync/atomic"
+)
+
+// maxPacketSize — наибольший размер UDP-датаграммы.
+const maxPacketSize = 65535
+
+// Listener принимает строки StatsD по UDP и накапливает их в буфере.
+// В одной датаграмме может быть несколько строк, разделенных переводом строки.
+type Listener struct {
+	conn    net.PacketConn
+	buffer  *Buffer
+	invalid atomic.Int64 // Число отброшенных строк с прошлой выгрузки
+}
+
+// Listen открывает UDP-сокет на addr. Прием начинается после вызова Serve.
+func Listen(addr string, buffer *Buffer) (*Listener, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen statsd address: %w", err)
+	}
+	return &Listener{conn: conn, buffer: buffer}, nil
+}
+
+// Addr возвращает адрес, на котором принимаются метрики.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// Serve принимает датаграммы до закрытия Listener.
+func (l *Listener) Serve() error {
+	packet := make([]byte, maxPacketSize)
+	for {
+		n, _, err := l.conn.ReadFrom(packet)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		l.handle(packet[:n])
+	}
+}
+
+func (l *Listener) handle(packet []byte) {
+	for _, line := range bytes.Split(packet, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		sample, err := Parse(string(line))
+		if err != nil {
+			l.invalid.Add(1)
+			continue
+		}
+		l.buffer.Add(sample)
+	}
+}
+
+// Flush возвращает метрики за интервал, см. Buffer.Flush. Число отброшенных
+// строк передается счетчиком StatsDInvalidLines.
+func (l *Listener) Flush() []models.Metrics {
+	result := l.buffer.Flush()
+	if invalid := l.invalid.Swap(0); invalid > 0 {
+		result = append(result, models.Metrics{ID: "StatsDInvalidLines", MType: "counter", Delta: &invalid})
+	}
+	return result
+}
+
+// Restore возвращает неотправленный результат Flush в буфер.
+func (l *Listener) Restore(metrics []models.Metrics) {
+	l.buffer.Restore(metrics)
+}
+
+// Close закрывает сокет; Serve после этого завершается.
+func (l *Listener) Close() error {
+	return l.conn.Close()
+}
@@ -0,0 +1,41 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListener(t *testing.T) {
+	b, err := NewBuffer(TimersHistogram)
+	require.NoError(t, err)
+	l, err := Listen("127.0.0.1:0", b)
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- l.Serve() }()
+
+	conn, err := net.Dial("udp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hits:1|c\nhits:2|c|#env:prod\nbroken\n"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("temp:12.5|g"))
+	require.NoError(t, err)
+
+	var metrics []models.Metrics
+	require.Eventually(t, func() bool {
+		metrics = append(metrics, l.Flush()...)
+		return findMetric(metrics, "gauge", "temp") != nil && findMetric(metrics, "counter", "StatsDInvalidLines") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 12.5, *findMetric(metrics, "gauge", "temp").Value)
+	assert.Equal(t, int64(1), *findMetric(metrics, "counter", "StatsDInvalidLines").Delta)
+
+	require.NoError(t, l.Close())
+	assert.NoError(t, <-done)
+}
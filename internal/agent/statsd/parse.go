@@ -0,0 +1,98 @@
+// Package statsd реализует прием метрик приложений по протоколу StatsD через UDP.
+// Значения накапливаются за интервал отправки и добавляются агентом
+// в очередной пакет: счетчики — дельтами, gauge — последним значением,
+// таймеры — гистограммой или сводкой.
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Типы метрик протокола StatsD.
+const (
+	TypeCounter   = "c"
+	TypeGauge     = "g"
+	TypeTimer     = "ms"
+	TypeHistogram = "h" // Обрабатывается так же, как таймер
+)
+
+// ErrInvalidLine возвращается для строк, не соответствующих протоколу.
+var ErrInvalidLine = errors.New("invalid statsd line")
+
+// Sample — одно значение, разобранное из строки протокола.
+type Sample struct {
+	Name     string
+	Value    float64
+	Type     string
+	Rate     float64           // Частота выборки из (0, 1], по умолчанию 1
+	Relative bool              // Для gauge: значение со знаком изменяет текущее
+	Tags     map[string]string // Теги вида #key:value,flag
+}
+
+// Parse разбирает строку вида name:value|type[|@rate][|#tag:value,...].
+// Тег без значения получает значение "true".
+func Parse(line string) (Sample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" || strings.ContainsAny(name, " |#") {
+		return Sample{}, fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return Sample{}, fmt.Errorf("%w: missing type in %q", ErrInvalidLine, line)
+	}
+
+	s := Sample{Name: name, Type: fields[1], Rate: 1}
+	switch s.Type {
+	case TypeCounter, TypeGauge, TypeTimer, TypeHistogram:
+	default:
+		return Sample{}, fmt.Errorf("%w: unsupported type %q", ErrInvalidLine, s.Type)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("%w: invalid value %q", ErrInvalidLine, fields[0])
+	}
+	s.Value = value
+	s.Relative = s.Type == TypeGauge && (fields[0][0] == '+' || fields[0][0] == '-')
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return Sample{}, fmt.Errorf("%w: invalid sample rate %q", ErrInvalidLine, field)
+			}
+			s.Rate = rate
+		case strings.HasPrefix(field, "#"):
+			s.Tags = parseTags(field[1:])
+		default:
+			return Sample{}, fmt.Errorf("%w: unknown field %q", ErrInvalidLine, field)
+		}
+	}
+	return s, nil
+}
+
+func parseTags(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ",") {
+		if tag == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(tag, ":")
+		if k == "" {
+			continue
+		}
+		if !ok {
+			v = "true"
+		}
+		tags[k] = v
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
@@ -0,0 +1,51 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want Sample
+	}{
+		{"hits:1|c", Sample{Name: "hits", Value: 1, Type: TypeCounter, Rate: 1}},
+		{"temp:12.5|g", Sample{Name: "temp", Value: 12.5, Type: TypeGauge, Rate: 1}},
+		{"temp:-3|g", Sample{Name: "temp", Value: -3, Type: TypeGauge, Rate: 1, Relative: true}},
+		{"latency:320|ms", Sample{Name: "latency", Value: 320, Type: TypeTimer, Rate: 1}},
+		{"size:42|h", Sample{Name: "size", Value: 42, Type: TypeHistogram, Rate: 1}},
+		{"hits:1|c|@0.1", Sample{Name: "hits", Value: 1, Type: TypeCounter, Rate: 0.1}},
+		{
+			"latency:320|ms|@0.5|#env:prod,canary",
+			Sample{Name: "latency", Value: 320, Type: TypeTimer, Rate: 0.5, Tags: map[string]string{"env": "prod", "canary": "true"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := Parse(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"hits",
+		":1|c",
+		"hits:1",
+		"hits:abc|c",
+		"hits:1|x",
+		"hits:1|s",
+		"hits:1|c|@0",
+		"hits:1|c|@2",
+		"hits:1|c|foo",
+	} {
+		_, err := Parse(line)
+		assert.ErrorIs(t, err, ErrInvalidLine, line)
+	}
+}
@@ -0,0 +1,114 @@
+// Package configfile загружает конфигурацию сервера и агента из файла
+// в формате JSON или YAML.
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvName — переменная окружения с путем к файлу конфигурации.
+const EnvName = "CONFIG"
+
+// Load читает файл path в структуру v. Формат определяется по расширению:
+// .json — JSON, остальные — YAML. Неизвестные ключи считаются ошибкой.
+func Load(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		return nil
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Path возвращает путь к файлу конфигурации из флага -c (-config)
+// или, если флаг не задан, из переменной окружения CONFIG.
+// Флаги разбираются заранее, так как файл нужен до разбора остальных флагов.
+func Path(args []string) string {
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		if name == args[i] {
+			continue
+		}
+		value, hasValue := "", false
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, value, hasValue = name[:idx], name[idx+1:], true
+		}
+		if name != "c" && name != "config" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		return value
+	}
+	return os.Getenv(EnvName)
+}
+
+// Duration — интервал в файле конфигурации. Задается строкой в формате
+// time.ParseDuration ("10s", "1m") или числом секунд.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	return d.set(raw)
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var raw interface{}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	return d.set(raw)
+}
+
+func (d *Duration) set(raw interface{}) error {
+	switch v := raw.(type) {
+	case string:
+		if dur, err := time.ParseDuration(v); err == nil {
+			d.Duration = dur
+			return nil
+		}
+		sec, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		d.Duration = time.Duration(sec) * time.Second
+	case float64:
+		d.Duration = time.Duration(v * float64(time.Second))
+	case int:
+		d.Duration = time.Duration(v) * time.Second
+	default:
+		return fmt.Errorf("invalid duration %v", raw)
+	}
+	return nil
+}
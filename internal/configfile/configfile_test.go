@@ -0,0 +1,74 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Address  *string   `json:"address" yaml:"address"`
+	Interval *Duration `json:"interval" yaml:"interval"`
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		interval time.Duration
+	}{
+		{"json duration string", "cfg.json", `{"address":"host:1","interval":"1m"}`, time.Minute},
+		{"json seconds", "cfg.json", `{"address":"host:1","interval":30}`, 30 * time.Second},
+		{"yaml duration string", "cfg.yaml", "address: host:1\ninterval: 500ms\n", 500 * time.Millisecond},
+		{"yaml seconds", "cfg.yml", "address: host:1\ninterval: 15\n", 15 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testConfig
+			require.NoError(t, Load(writeFile(t, tt.file, tt.content), &cfg))
+			assert.Equal(t, "host:1", *cfg.Address)
+			assert.Equal(t, tt.interval, cfg.Interval.Duration)
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	var cfg testConfig
+
+	err := Load(writeFile(t, "cfg.json", `{"addres":"typo"}`), &cfg)
+	assert.ErrorContains(t, err, "addres")
+
+	err = Load(writeFile(t, "cfg.yaml", "addres: typo\n"), &cfg)
+	assert.ErrorContains(t, err, "addres")
+
+	err = Load(writeFile(t, "cfg.yaml", "interval: soon\n"), &cfg)
+	assert.Error(t, err)
+
+	err = Load(filepath.Join(t.TempDir(), "missing.json"), &cfg)
+	assert.Error(t, err)
+
+	// Пустой файл допустим
+	assert.NoError(t, Load(writeFile(t, "cfg.yaml", ""), &cfg))
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv(EnvName, "/etc/env.yaml")
+
+	assert.Equal(t, "/etc/flag.yaml", Path([]string{"-a=:8080", "-c", "/etc/flag.yaml"}))
+	assert.Equal(t, "/etc/flag.yaml", Path([]string{"-config=/etc/flag.yaml"}))
+	assert.Equal(t, "/etc/flag.yaml", Path([]string{"--c=/etc/flag.yaml"}))
+	assert.Equal(t, "/etc/env.yaml", Path([]string{"-a=:8080"}))
+}
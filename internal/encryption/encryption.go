@@ -0,0 +1,184 @@
+// Package encryption реализует гибридное шифрование тела запросов агента:
+// данные шифруются одноразовым ключом AES-GCM, а сам ключ — открытым ключом
+// RSA сервера (RSA-OAEP, SHA-256). Размер сообщения поэтому не ограничен
+// размером RSA-ключа.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// Header — заголовок, которым агент помечает зашифрованное тело запроса.
+	Header = "X-Encryption"
+	// Scheme — значение заголовка Header для используемой схемы шифрования.
+	Scheme = "rsa-oaep+aes-gcm"
+
+	sessionKeySize = 32
+)
+
+var (
+	// ErrInvalidKey возвращается, если файл не содержит ключ RSA подходящего типа.
+	ErrInvalidKey = errors.New("invalid RSA key")
+	// ErrMalformedMessage возвращается, если зашифрованное сообщение повреждено.
+	ErrMalformedMessage = errors.New("malformed encrypted message")
+)
+
+// LoadPublicKey читает открытый ключ RSA из PEM-файла. Поддерживаются
+// форматы PKIX ("PUBLIC KEY"), PKCS#1 ("RSA PUBLIC KEY") и сертификаты X.509.
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		return key, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		return rsaPublicKey(cert.PublicKey)
+	default:
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		return rsaPublicKey(key)
+	}
+}
+
+// LoadPrivateKey читает закрытый ключ RSA из PEM-файла в формате PKCS#1 или PKCS#8.
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported key type %T", ErrInvalidKey, key)
+	}
+	return rsaKey, nil
+}
+
+// Encrypt шифрует данные. Формат результата: длина зашифрованного
+// сессионного ключа (2 байта, big-endian), сам ключ, nonce и шифротекст AES-GCM.
+func Encrypt(key *rsa.PublicKey, data []byte) ([]byte, error) {
+	sessionKey := make([]byte, sessionKeySize)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, sessionKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt session key: %w", err)
+	}
+
+	gcm, err := newGCM(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 2, 2+len(encryptedKey)+len(nonce)+len(data)+gcm.Overhead())
+	binary.BigEndian.PutUint16(out, uint16(len(encryptedKey)))
+	out = append(out, encryptedKey...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, nil), nil
+}
+
+// Decrypt расшифровывает сообщение, полученное от Encrypt.
+func Decrypt(key *rsa.PrivateKey, message []byte) ([]byte, error) {
+	if len(message) < 2 {
+		return nil, ErrMalformedMessage
+	}
+	keyLen := int(binary.BigEndian.Uint16(message))
+	message = message[2:]
+	if len(message) < keyLen {
+		return nil, ErrMalformedMessage
+	}
+
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, message[:keyLen], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedMessage, err)
+	}
+	message = message[keyLen:]
+
+	gcm, err := newGCM(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) < gcm.NonceSize() {
+		return nil, ErrMalformedMessage
+	}
+
+	data, err := gcm.Open(nil, message[:gcm.NonceSize()], message[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedMessage, err)
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM data in %s", ErrInvalidKey, path)
+	}
+	return block, nil
+}
+
+func rsaPublicKey(key interface{}) (*rsa.PublicKey, error) {
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported key type %T", ErrInvalidKey, key)
+	}
+	return rsaKey, nil
+}
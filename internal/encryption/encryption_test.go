@@ -0,0 +1,81 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeys генерирует пару ключей и сохраняет ее в PEM-файлы во временном каталоге.
+func writeKeys(t *testing.T) (publicPath, privatePath string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	privatePath = filepath.Join(dir, "private.pem")
+	publicPath = filepath.Join(dir, "public.pem")
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600))
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644))
+	return publicPath, privatePath
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	publicPath, privatePath := writeKeys(t)
+
+	publicKey, err := LoadPublicKey(publicPath)
+	require.NoError(t, err)
+	privateKey, err := LoadPrivateKey(privatePath)
+	require.NoError(t, err)
+
+	// Сообщение больше, чем можно зашифровать одним блоком RSA
+	data := make([]byte, 64*1024)
+	rand.Read(data)
+
+	encrypted, err := Encrypt(publicKey, data)
+	require.NoError(t, err)
+	assert.NotEqual(t, data, encrypted)
+
+	decrypted, err := Decrypt(privateKey, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, data, decrypted)
+
+	t.Run("tampered message", func(t *testing.T) {
+		tampered := append([]byte(nil), encrypted...)
+		tampered[len(tampered)-1] ^= 0xff
+		_, err := Decrypt(privateKey, tampered)
+		assert.ErrorIs(t, err, ErrMalformedMessage)
+	})
+
+	t.Run("truncated message", func(t *testing.T) {
+		_, err := Decrypt(privateKey, encrypted[:10])
+		assert.ErrorIs(t, err, ErrMalformedMessage)
+	})
+}
+
+func TestLoadKeys_Errors(t *testing.T) {
+	publicPath, privatePath := writeKeys(t)
+
+	_, err := LoadPublicKey(privatePath)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = LoadPrivateKey(publicPath)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = LoadPrivateKey(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
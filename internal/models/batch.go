@@ -0,0 +1,35 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+// IdempotencyKeyHeader — заголовок с ключом идемпотентности пакета метрик.
+// Повтор пакета с тем же ключом сервер не применяет повторно.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// BatchRejectedHeader — заголовок ответа, которым сервер отмечает пакет,
+// отклоненный из-за его содержимого (неверный JSON, недопустимая метрика).
+// Ошибки подписи, шифрования и доступа им не отмечаются: такой пакет
+// будет принят, когда исправят настройки агента или сервера.
+const BatchRejectedHeader = "X-Batch-Rejected"
+
+// ErrBatchRejected означает, что сервер отклонил пакет из-за его содержимого
+// и повторная отправка того же пакета не будет принята.
+var ErrBatchRejected = errors.New("batch rejected by server")
+
+// Batch — пакет метрик вместе с его идентификатором, который передается
+// серверу как ключ идемпотентности.
+type Batch struct {
+	ID      string    `json:"id"`
+	Metrics []Metrics `json:"metrics"`
+}
+
+// NewBatchID возвращает случайный идентификатор пакета.
+func NewBatchID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
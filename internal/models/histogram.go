@@ -0,0 +1,140 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ErrBucketsMismatch возвращается при слиянии гистограмм с разными границами корзин.
+var ErrBucketsMismatch = errors.New("histogram buckets mismatch")
+
+// DefaultBuckets — границы корзин по умолчанию (в секундах), как в клиентах Prometheus.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram описывает распределение наблюдений по корзинам.
+// Bounds — верхние границы корзин по возрастанию, корзина +Inf подразумевается.
+// Counts — число наблюдений в каждой корзине (не накопительное),
+// поэтому len(Counts) == len(Bounds)+1.
+// Как и delta у counter, значения гистограммы при обновлении суммируются.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+// NewHistogram создает пустую гистограмму с заданными границами корзин.
+func NewHistogram(bounds []float64) *Histogram {
+	b := make([]float64, len(bounds))
+	copy(b, bounds)
+	sort.Float64s(b)
+	return &Histogram{
+		Bounds: b,
+		Counts: make([]uint64, len(b)+1),
+	}
+}
+
+// Observe добавляет наблюдение в гистограмму.
+func (h *Histogram) Observe(v float64) {
+	h.ObserveN(v, 1)
+}
+
+// ObserveN добавляет n одинаковых наблюдений в гистограмму.
+func (h *Histogram) ObserveN(v float64, n uint64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i] += n
+	h.Sum += v * float64(n)
+	h.Count += n
+}
+
+// Validate проверяет согласованность границ и счетчиков гистограммы.
+func (h *Histogram) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("histogram must have %d counts for %d bounds, got %d",
+			len(h.Bounds)+1, len(h.Bounds), len(h.Counts))
+	}
+	for i, b := range h.Bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return fmt.Errorf("histogram bound %v is not finite", b)
+		}
+		if i > 0 && b <= h.Bounds[i-1] {
+			return errors.New("histogram bounds must be strictly increasing")
+		}
+	}
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total != h.Count {
+		return fmt.Errorf("histogram count %d does not match bucket total %d", h.Count, total)
+	}
+	return nil
+}
+
+// Merge прибавляет к гистограмме значения other.
+// Границы корзин обеих гистограмм должны совпадать.
+func (h *Histogram) Merge(other Histogram) error {
+	if len(h.Bounds) != len(other.Bounds) || len(h.Counts) != len(other.Counts) {
+		return ErrBucketsMismatch
+	}
+	for i := range h.Bounds {
+		if h.Bounds[i] != other.Bounds[i] {
+			return ErrBucketsMismatch
+		}
+	}
+	for i := range h.Counts {
+		h.Counts[i] += other.Counts[i]
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+	return nil
+}
+
+// Clone возвращает независимую копию гистограммы.
+func (h Histogram) Clone() Histogram {
+	c := h
+	c.Bounds = append([]float64(nil), h.Bounds...)
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return c
+}
+
+// Quantile — значение квантиля в сводке.
+type Quantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// Summary — сводка наблюдений с заранее посчитанными квантилями.
+// При обновлении квантили заменяются последними значениями, а Sum и Count
+// суммируются так же, как delta у counter.
+type Summary struct {
+	Quantiles []Quantile `json:"quantiles"`
+	Sum       float64    `json:"sum"`
+	Count     uint64     `json:"count"`
+}
+
+// Validate проверяет корректность квантилей сводки.
+func (s *Summary) Validate() error {
+	for _, q := range s.Quantiles {
+		if math.IsNaN(q.Quantile) || q.Quantile < 0 || q.Quantile > 1 {
+			return fmt.Errorf("summary quantile %v is out of range [0, 1]", q.Quantile)
+		}
+	}
+	return nil
+}
+
+// Merge применяет к сводке значения other.
+func (s *Summary) Merge(other Summary) {
+	s.Quantiles = append([]Quantile(nil), other.Quantiles...)
+	s.Sum += other.Sum
+	s.Count += other.Count
+}
+
+// Clone возвращает независимую копию сводки.
+func (s Summary) Clone() Summary {
+	c := s
+	c.Quantiles = append([]Quantile(nil), s.Quantiles...)
+	return c
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]float64{1, 0.1, 0.5})
+	assert.Equal(t, []float64{0.1, 0.5, 1}, h.Bounds)
+
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	assert.Equal(t, []uint64{1, 1, 0, 1}, h.Counts)
+	assert.Equal(t, uint64(3), h.Count)
+	assert.InDelta(t, 3.55, h.Sum, 1e-9)
+	assert.NoError(t, h.Validate())
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a := NewHistogram([]float64{1, 2})
+	a.Observe(0.5)
+	b := NewHistogram([]float64{1, 2})
+	b.Observe(1.5)
+	b.Observe(10)
+
+	require.NoError(t, a.Merge(*b))
+	assert.Equal(t, []uint64{1, 1, 1}, a.Counts)
+	assert.Equal(t, uint64(3), a.Count)
+
+	c := NewHistogram([]float64{1, 3})
+	assert.ErrorIs(t, a.Merge(*c), ErrBucketsMismatch)
+}
+
+func TestHistogram_Validate(t *testing.T) {
+	h := Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 2}}
+	assert.Error(t, h.Validate())
+
+	h = Histogram{Bounds: []float64{2, 1}, Counts: []uint64{0, 0, 0}}
+	assert.Error(t, h.Validate())
+
+	h = Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 0, 0}, Count: 2}
+	assert.Error(t, h.Validate())
+}
+
+func TestSummary_Merge(t *testing.T) {
+	s := Summary{Quantiles: []Quantile{{Quantile: 0.5, Value: 1}}, Sum: 10, Count: 5}
+	s.Merge(Summary{Quantiles: []Quantile{{Quantile: 0.5, Value: 2}}, Sum: 4, Count: 1})
+
+	assert.Equal(t, []Quantile{{Quantile: 0.5, Value: 2}}, s.Quantiles)
+	assert.Equal(t, 14.0, s.Sum)
+	assert.Equal(t, uint64(6), s.Count)
+
+	bad := Summary{Quantiles: []Quantile{{Quantile: 1.5}}}
+	assert.Error(t, bad.Validate())
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// HistoryPoint — значение метрики в момент времени.
+// Для counter значением является накопленная сумма.
+type HistoryPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
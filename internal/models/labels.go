@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LabelsKey возвращает каноническое представление набора меток.
+// Для пустого набора возвращается пустая строка, иначе — JSON-объект
+// с ключами в алфавитном порядке.
+func LabelsKey(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(labels)
+	return string(data)
+}
+
+// ParseLabelsKey восстанавливает набор меток из представления LabelsKey.
+func ParseLabelsKey(key string) (map[string]string, error) {
+	if key == "" {
+		return nil, nil
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(key), &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// MatchLabels сообщает, содержит ли набор labels все метки из filter.
+func MatchLabels(labels, filter map[string]string) bool {
+	for k, v := range filter {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateLabels проверяет, что имена меток непустые.
+func ValidateLabels(labels map[string]string) error {
+	for k := range labels {
+		if k == "" {
+			return errors.New("label name must not be empty")
+		}
+	}
+	return nil
+}
+
+// SeriesName возвращает имя ряда вида name{key="value",...} для отображения.
+func SeriesName(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
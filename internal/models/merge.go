@@ -0,0 +1,83 @@
+package models
+
+// MergeMetrics объединяет два последовательных пакета в один: дельты счетчиков
+// складываются, гистограммы и summary объединяются, для gauge остается
+// более позднее значение. Порядок метрик сохраняется по первому появлению.
+// Гистограммы одного ряда с разными границами корзин не объединяются:
+// в пакете остаются обе, и более поздние значения объединяются с последней.
+func MergeMetrics(older, newer []Metrics) []Metrics {
+	type key struct {
+		mType, id, labels string
+	}
+
+	result := make([]Metrics, 0, len(older)+len(newer))
+	index := make(map[key]int, len(older)+len(newer))
+
+	for _, batch := range [][]Metrics{older, newer} {
+		for _, metric := range batch {
+			k := key{metric.MType, metric.ID, LabelsKey(metric.Labels)}
+			i, ok := index[k]
+			if !ok {
+				index[k] = len(result)
+				result = append(result, cloneMetric(metric))
+				continue
+			}
+			merged, ok := combineMetrics(result[i], metric)
+			if !ok {
+				index[k] = len(result)
+				result = append(result, cloneMetric(metric))
+				continue
+			}
+			result[i] = merged
+		}
+	}
+	return result
+}
+
+// combineMetrics объединяет накопленное значение метрики с более поздним.
+// Возвращает false, если значения нельзя объединить без потери данных:
+// у гистограмм разные границы корзин.
+func combineMetrics(acc, next Metrics) (Metrics, bool) {
+	switch acc.MType {
+	case "counter":
+		if acc.Delta != nil && next.Delta != nil {
+			sum := *acc.Delta + *next.Delta
+			acc.Delta = &sum
+			return acc, true
+		}
+	case "histogram":
+		if acc.Histogram != nil && next.Histogram != nil {
+			if err := acc.Histogram.Merge(*next.Histogram); err != nil {
+				return acc, false
+			}
+			return acc, true
+		}
+	case "summary":
+		if acc.Summary != nil && next.Summary != nil {
+			acc.Summary.Merge(*next.Summary)
+			return acc, true
+		}
+	}
+	return cloneMetric(next), true
+}
+
+// cloneMetric копирует значения метрики, чтобы объединение не меняло исходные пакеты.
+func cloneMetric(metric Metrics) Metrics {
+	if metric.Delta != nil {
+		delta := *metric.Delta
+		metric.Delta = &delta
+	}
+	if metric.Value != nil {
+		value := *metric.Value
+		metric.Value = &value
+	}
+	if metric.Histogram != nil {
+		h := metric.Histogram.Clone()
+		metric.Histogram = &h
+	}
+	if metric.Summary != nil {
+		s := metric.Summary.Clone()
+		metric.Summary = &s
+	}
+	return metric
+}
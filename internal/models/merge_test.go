@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gaugeMetric(id string, value float64) Metrics {
+	return Metrics{ID: id, MType: "gauge", Value: &value}
+}
+
+func counterMetric(id string, delta int64) Metrics {
+	return Metrics{ID: id, MType: "counter", Delta: &delta}
+}
+
+func TestMergeMetrics(t *testing.T) {
+	h1 := NewHistogram([]float64{1})
+	h1.Observe(0.5)
+	h2 := NewHistogram([]float64{1})
+	h2.Observe(2)
+
+	older := []Metrics{counterMetric("c", 2), gaugeMetric("g", 1), {ID: "h", MType: "histogram", Histogram: h1}}
+	newer := []Metrics{gaugeMetric("g", 5), counterMetric("c", 3), {ID: "h", MType: "histogram", Histogram: h2}, gaugeMetric("new", 1)}
+
+	merged := MergeMetrics(older, newer)
+	require.Len(t, merged, 4)
+	assert.Equal(t, int64(5), *merged[0].Delta)
+	assert.Equal(t, 5.0, *merged[1].Value)
+	assert.Equal(t, uint64(2), merged[2].Histogram.Count)
+	assert.Equal(t, "new", merged[3].ID)
+
+	// Исходные пакеты не изменяются
+	assert.Equal(t, int64(2), *older[0].Delta)
+	assert.Equal(t, uint64(1), h1.Count)
+}
+
+func TestMergeMetrics_HistogramBoundsMismatch(t *testing.T) {
+	h1 := NewHistogram([]float64{1})
+	h1.Observe(0.5)
+	h2 := NewHistogram([]float64{1, 5})
+	h2.Observe(2)
+	h3 := NewHistogram([]float64{1, 5})
+	h3.Observe(3)
+
+	// Более старая гистограмма не теряется, новые значения объединяются с последней
+	merged := MergeMetrics(
+		[]Metrics{{ID: "h", MType: "histogram", Histogram: h1}},
+		[]Metrics{{ID: "h", MType: "histogram", Histogram: h2}, {ID: "h", MType: "histogram", Histogram: h3}},
+	)
+	require.Len(t, merged, 2)
+	assert.Equal(t, []float64{1}, merged[0].Histogram.Bounds)
+	assert.Equal(t, uint64(1), merged[0].Histogram.Count)
+	assert.Equal(t, []float64{1, 5}, merged[1].Histogram.Bounds)
+	assert.Equal(t, uint64(2), merged[1].Histogram.Count)
+}
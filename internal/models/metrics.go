@@ -1,8 +1,29 @@
 package models
 
 type Metrics struct {
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
-	Delta *int64   `json:"delta,omitempty"`
-	Value *float64 `json:"value,omitempty"`
+	ID        string            `json:"id"`
+	MType     string            `json:"type"`
+	Delta     *int64            `json:"delta,omitempty"`
+	Value     *float64          `json:"value,omitempty"`
+	Histogram *Histogram        `json:"histogram,omitempty"`
+	Summary   *Summary          `json:"summary,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// ValidateMetric проверяет метки, а также значения histogram и summary метрик.
+func ValidateMetric(metric Metrics) error {
+	if err := ValidateLabels(metric.Labels); err != nil {
+		return err
+	}
+	switch metric.MType {
+	case "histogram":
+		if metric.Histogram != nil {
+			return metric.Histogram.Validate()
+		}
+	case "summary":
+		if metric.Summary != nil {
+			return metric.Summary.Validate()
+		}
+	}
+	return nil
 }
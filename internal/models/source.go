@@ -0,0 +1,29 @@
+package models
+
+// SourceLabel — метка, в которой сервер хранит идентификатор агента-источника.
+const SourceLabel = "source"
+
+// AgentIDHeader — заголовок, в котором агент передает свой идентификатор.
+const AgentIDHeader = "X-Agent-ID"
+
+// Source — сводная информация об источнике метрик.
+type Source struct {
+	ID      string `json:"id"`
+	Metrics int    `json:"metrics"`
+}
+
+// SetSource добавляет к метрикам метку источника source. Наборы меток
+// копируются, чтобы не изменять данные вызывающей стороны.
+func SetSource(metrics []Metrics, source string) {
+	if source == "" {
+		return
+	}
+	for i := range metrics {
+		labels := make(map[string]string, len(metrics[i].Labels)+1)
+		for k, v := range metrics[i].Labels {
+			labels[k] = v
+		}
+		labels[SourceLabel] = source
+		metrics[i].Labels = labels
+	}
+}
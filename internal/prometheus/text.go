@@ -0,0 +1,202 @@
+// Package prometheus формирует представление метрик в текстовом формате
+// экспозиции Prometheus (версия 0.0.4).
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"go-metrics-server/internal/models"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType — значение заголовка Content-Type для текстового формата 0.0.4.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var typeHelp = map[string]string{
+	"gauge":     "Gauge",
+	"counter":   "Counter",
+	"histogram": "Histogram",
+	"summary":   "Summary",
+}
+
+// family — ряды одной метрики, выводимые под общими строками HELP и TYPE.
+type family struct {
+	name   string
+	mType  string
+	series []models.Metrics
+}
+
+// WriteText записывает метрики в текстовом формате Prometheus.
+// Ряды с одинаковым именем и типом объединяются в одно семейство,
+// метки рядов выводятся как метки Prometheus. Если под одним именем
+// хранятся метрики разных типов, к имени семейства добавляется суффикс типа.
+func WriteText(w io.Writer, metrics []models.Metrics) error {
+	families := groupFamilies(metrics)
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s metric %s.\n", f.name, typeHelp[f.mType], escapeHelp(f.series[0].ID))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.mType)
+
+		for _, m := range f.series {
+			switch f.mType {
+			case "gauge":
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, formatLabels(m.Labels), formatFloat(*m.Value))
+			case "counter":
+				fmt.Fprintf(bw, "%s%s %d\n", f.name, formatLabels(m.Labels), *m.Delta)
+			case "histogram":
+				h := m.Histogram
+				var cumulative uint64
+				for i, bound := range h.Bounds {
+					if i < len(h.Counts) {
+						cumulative += h.Counts[i]
+					}
+					fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, formatLabels(m.Labels, "le", formatFloat(bound)), cumulative)
+				}
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, formatLabels(m.Labels, "le", "+Inf"), h.Count)
+				fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, formatLabels(m.Labels), formatFloat(h.Sum))
+				fmt.Fprintf(bw, "%s_count%s %d\n", f.name, formatLabels(m.Labels), h.Count)
+			case "summary":
+				s := m.Summary
+				for _, q := range s.Quantiles {
+					fmt.Fprintf(bw, "%s%s %s\n", f.name, formatLabels(m.Labels, "quantile", formatFloat(q.Quantile)), formatFloat(q.Value))
+				}
+				fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, formatLabels(m.Labels), formatFloat(s.Sum))
+				fmt.Fprintf(bw, "%s_count%s %d\n", f.name, formatLabels(m.Labels), s.Count)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// groupFamilies группирует ряды по имени и типу и упорядочивает семейства по имени.
+func groupFamilies(metrics []models.Metrics) []*family {
+	byKey := make(map[string]*family)
+	types := make(map[string][]string)
+	for _, m := range metrics {
+		if !hasValue(m) {
+			continue
+		}
+		name := SanitizeName(m.ID)
+		key := name + " " + m.MType
+		f, ok := byKey[key]
+		if !ok {
+			f = &family{name: name, mType: m.MType}
+			byKey[key] = f
+			types[name] = append(types[name], m.MType)
+		}
+		f.series = append(f.series, m)
+	}
+
+	families := make([]*family, 0, len(byKey))
+	for _, f := range byKey {
+		if len(types[f.name]) > 1 {
+			f.name += "_" + f.mType
+		}
+		sort.Slice(f.series, func(i, j int) bool {
+			return models.LabelsKey(f.series[i].Labels) < models.LabelsKey(f.series[j].Labels)
+		})
+		families = append(families, f)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+	return families
+}
+
+func hasValue(m models.Metrics) bool {
+	switch m.MType {
+	case "gauge":
+		return m.Value != nil
+	case "counter":
+		return m.Delta != nil
+	case "histogram":
+		return m.Histogram != nil
+	case "summary":
+		return m.Summary != nil
+	}
+	return false
+}
+
+// formatLabels формирует блок меток {k="v",...}; extra — дополнительная пара
+// имя-значение (le или quantile), выводимая последней.
+func formatLabels(labels map[string]string, extra ...string) string {
+	if len(labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", sanitizeLabelName(k), escapeLabelValue(labels[k]))
+	}
+	if len(extra) == 2 {
+		if len(keys) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extra[0], extra[1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// SanitizeName приводит имя метрики к виду [a-zA-Z_:][a-zA-Z0-9_:]*,
+// заменяя недопустимые символы на подчеркивание.
+func SanitizeName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// sanitizeLabelName приводит имя метки к виду [a-zA-Z_][a-zA-Z0-9_]*.
+func sanitizeLabelName(name string) string {
+	return strings.ReplaceAll(SanitizeName(name), ":", "_")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func escapeLabelValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
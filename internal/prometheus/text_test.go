@@ -0,0 +1,110 @@
+package prometheus
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gauge(id string, v float64, labels map[string]string) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &v, Labels: labels}
+}
+
+func counter(id string, d int64, labels map[string]string) models.Metrics {
+	return models.Metrics{ID: id, MType: "counter", Delta: &d, Labels: labels}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteText(&buf, []models.Metrics{
+		gauge("HeapAlloc", 123.5, nil),
+		counter("PollCount", 7, nil),
+		gauge("Inf", math.Inf(1), nil),
+		{ID: "empty", MType: "gauge"},
+	})
+	require.NoError(t, err)
+
+	expected := "# HELP HeapAlloc Gauge metric HeapAlloc.\n" +
+		"# TYPE HeapAlloc gauge\n" +
+		"HeapAlloc 123.5\n" +
+		"# HELP Inf Gauge metric Inf.\n" +
+		"# TYPE Inf gauge\n" +
+		"Inf +Inf\n" +
+		"# HELP PollCount Counter metric PollCount.\n" +
+		"# TYPE PollCount counter\n" +
+		"PollCount 7\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteText_Labels(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteText(&buf, []models.Metrics{
+		gauge("cpu", 20, map[string]string{"core": "2"}),
+		gauge("cpu", 10, map[string]string{"core": "1", "host": `a"b`}),
+		counter("test", 1, nil),
+		gauge("test", 2, nil),
+	})
+	require.NoError(t, err)
+
+	expected := "# HELP cpu Gauge metric cpu.\n" +
+		"# TYPE cpu gauge\n" +
+		"cpu{core=\"1\",host=\"a\\\"b\"} 10\n" +
+		"cpu{core=\"2\"} 20\n" +
+		"# HELP test_counter Counter metric test.\n" +
+		"# TYPE test_counter counter\n" +
+		"test_counter 1\n" +
+		"# HELP test_gauge Gauge metric test.\n" +
+		"# TYPE test_gauge gauge\n" +
+		"test_gauge 2\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteText_Composite(t *testing.T) {
+	h := models.NewHistogram([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(2)
+
+	var buf bytes.Buffer
+	err := WriteText(&buf, []models.Metrics{
+		{ID: "latency", MType: "histogram", Histogram: h, Labels: map[string]string{"path": "/"}},
+		{ID: "rt", MType: "summary", Summary: &models.Summary{
+			Quantiles: []models.Quantile{{Quantile: 0.5, Value: 0.2}},
+			Sum:       1.5,
+			Count:     4,
+		}},
+	})
+	require.NoError(t, err)
+
+	expected := "# HELP latency Histogram metric latency.\n" +
+		"# TYPE latency histogram\n" +
+		"latency_bucket{path=\"/\",le=\"0.1\"} 1\n" +
+		"latency_bucket{path=\"/\",le=\"1\"} 2\n" +
+		"latency_bucket{path=\"/\",le=\"+Inf\"} 3\n" +
+		"latency_sum{path=\"/\"} 2.55\n" +
+		"latency_count{path=\"/\"} 3\n" +
+		"# HELP rt Summary metric rt.\n" +
+		"# TYPE rt summary\n" +
+		"rt{quantile=\"0.5\"} 0.2\n" +
+		"rt_sum 1.5\n" +
+		"rt_count 4\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"Alloc":         "Alloc",
+		"cpu.usage":     "cpu_usage",
+		"1st":           "_1st",
+		"name:sub_part": "name:sub_part",
+		"":              "_",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, SanitizeName(in), in)
+	}
+}
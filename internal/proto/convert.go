@@ -0,0 +1,88 @@
+package proto
+
+import (
+	"fmt"
+	"go-metrics-server/internal/models"
+)
+
+// FromModel преобразует models.Metrics в сообщение Metric.
+func FromModel(m models.Metrics) *Metric {
+	metric := &Metric{
+		Id:     m.ID,
+		Type:   m.MType,
+		Delta:  m.Delta,
+		Value:  m.Value,
+		Labels: m.Labels,
+	}
+	if m.Histogram != nil {
+		metric.Histogram = &Histogram{
+			Bounds: m.Histogram.Bounds,
+			Counts: m.Histogram.Counts,
+			Sum:    m.Histogram.Sum,
+			Count:  m.Histogram.Count,
+		}
+	}
+	if m.Summary != nil {
+		summary := &Summary{Sum: m.Summary.Sum, Count: m.Summary.Count}
+		for _, q := range m.Summary.Quantiles {
+			summary.Quantiles = append(summary.Quantiles, &Quantile{Quantile: q.Quantile, Value: q.Value})
+		}
+		metric.Summary = summary
+	}
+	return metric
+}
+
+// ToModel преобразует сообщение Metric в models.Metrics и проверяет,
+// что для типа метрики задано значение.
+func ToModel(m *Metric) (models.Metrics, error) {
+	metric := models.Metrics{
+		ID:     m.GetId(),
+		MType:  m.GetType(),
+		Delta:  m.Delta,
+		Value:  m.Value,
+		Labels: m.GetLabels(),
+	}
+	if len(metric.Labels) == 0 {
+		metric.Labels = nil
+	}
+	if h := m.GetHistogram(); h != nil {
+		metric.Histogram = &models.Histogram{
+			Bounds: h.GetBounds(),
+			Counts: h.GetCounts(),
+			Sum:    h.GetSum(),
+			Count:  h.GetCount(),
+		}
+	}
+	if s := m.GetSummary(); s != nil {
+		summary := &models.Summary{Sum: s.GetSum(), Count: s.GetCount()}
+		for _, q := range s.GetQuantiles() {
+			summary.Quantiles = append(summary.Quantiles, models.Quantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+		}
+		metric.Summary = summary
+	}
+
+	if metric.ID == "" {
+		return metric, fmt.Errorf("metric name is required")
+	}
+	switch metric.MType {
+	case "gauge":
+		if metric.Value == nil {
+			return metric, fmt.Errorf("value is required for gauge %s", metric.ID)
+		}
+	case "counter":
+		if metric.Delta == nil {
+			return metric, fmt.Errorf("delta is required for counter %s", metric.ID)
+		}
+	case "histogram":
+		if metric.Histogram == nil {
+			return metric, fmt.Errorf("histogram is required for histogram %s", metric.ID)
+		}
+	case "summary":
+		if metric.Summary == nil {
+			return metric, fmt.Errorf("summary is required for summary %s", metric.ID)
+		}
+	default:
+		return metric, fmt.Errorf("invalid metric type: %s", metric.MType)
+	}
+	return metric, models.ValidateMetric(metric)
+}
@@ -0,0 +1,47 @@
+package proto
+
+import (
+	"testing"
+
+	"go-metrics-server/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+	value := 1.5
+	delta := int64(7)
+	histogram := models.NewHistogram([]float64{1, 5})
+	histogram.Observe(3)
+
+	tests := []models.Metrics{
+		{ID: "g", MType: "gauge", Value: &value, Labels: map[string]string{"host": "a"}},
+		{ID: "c", MType: "counter", Delta: &delta},
+		{ID: "h", MType: "histogram", Histogram: histogram},
+		{ID: "s", MType: "summary", Summary: &models.Summary{
+			Quantiles: []models.Quantile{{Quantile: 0.5, Value: 2}},
+			Sum:       4,
+			Count:     2,
+		}},
+	}
+
+	for _, metric := range tests {
+		t.Run(metric.MType, func(t *testing.T) {
+			got, err := ToModel(FromModel(metric))
+			require.NoError(t, err)
+			assert.Equal(t, metric, got)
+		})
+	}
+}
+
+func TestToModel_Invalid(t *testing.T) {
+	_, err := ToModel(&Metric{Id: "g", Type: "gauge"})
+	assert.Error(t, err)
+
+	_, err = ToModel(&Metric{Type: "gauge"})
+	assert.Error(t, err)
+
+	_, err = ToModel(&Metric{Id: "x", Type: "unknown"})
+	assert.Error(t, err)
+}
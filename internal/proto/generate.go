@@ -0,0 +1,5 @@
+// Package proto содержит описание gRPC-сервиса метрик, сгенерированный по нему
+// код и преобразования между сообщениями и models.Metrics.
+package proto
+
+//go:generate protoc --proto_path=../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative internal/proto/metrics.proto
@@ -0,0 +1,48 @@
+package proto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// HashMetadata — ключ метаданных с HMAC-SHA256 подписью запроса,
+// аналог заголовка HashSHA256 HTTP-транспорта.
+const HashMetadata = "hashsha256"
+
+// Signer считает подпись последовательности сообщений: HMAC-SHA256
+// от их детерминированной сериализации, каждое сообщение с префиксом длины.
+// Для потока UpdateMetrics подписываются все сообщения потока по порядку.
+type Signer struct {
+	mac hash.Hash
+}
+
+func NewSigner(key string) *Signer {
+	return &Signer{mac: hmac.New(sha256.New, []byte(key))}
+}
+
+// Add добавляет сообщение к подписи.
+func (s *Signer) Add(msg proto.Message) error {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.mac.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	s.mac.Write(data)
+	return nil
+}
+
+// Sum возвращает подпись в шестнадцатеричном виде.
+func (s *Signer) Sum() string {
+	return hex.EncodeToString(s.mac.Sum(nil))
+}
+
+// Valid сообщает, совпадает ли подпись с hash.
+func (s *Signer) Valid(hash string) bool {
+	received, err := hex.DecodeString(hash)
+	return err == nil && hmac.Equal(received, s.mac.Sum(nil))
+}
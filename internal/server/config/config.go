@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"fmt"
+	"go-metrics-server/internal/configfile"
 	"log"
 	"os"
 	"strconv"
@@ -17,6 +18,11 @@ const (
 	defaultRestore       = true
 	defaultDatabaseDSN   = ""
 	defaultKey           = ""
+	defaultCryptoKey     = ""
+	defaultGRPCAddr      = ""
+	defaultTrustedSubnet = ""
+	// defaultHistoryRetention — срок хранения истории значений метрик.
+	defaultHistoryRetention = 7 * 24 * time.Hour
 )
 
 type Config struct {
@@ -26,18 +32,52 @@ type Config struct {
 	Restore       bool          // Загружать данные при старте
 	DatabaseDSN   string        // DSN для подключения к БД
 	Key           string        // Ключ для подписи данных
+	CryptoKey     string        // Путь к файлу с приватным ключом для расшифровки запросов
+	GRPCAddr      string        // Адрес gRPC-сервера, пустая строка отключает gRPC
+	TrustedSubnet string        // Доверенная подсеть (CIDR), из которой разрешена запись метрик
+
+	HistoryRetention time.Duration // Срок хранения истории значений; 0 — хранить без ограничения
 }
 
 func NewConfig() *Config {
 	cfg := &Config{}
 
-	// Получаем значения из переменных окружения или используем значения по умолчанию
-	serverAddr := getEnvOrDefault("ADDRESS", defaultServerAddr)
-	storeInterval := parseDuration(getEnvOrDefault("STORE_INTERVAL", defaultStoreInterval.String()))
-	fileStorage := getEnvOrDefault("FILE_STORAGE_PATH", defaultFileStorage)
-	restore := parseBool(getEnvOrDefault("RESTORE", strconv.FormatBool(defaultRestore)))
-	databaseDSN := getEnvOrDefault("DATABASE_DSN", defaultDatabaseDSN)
-	key := getEnvOrDefault("KEY", defaultKey)
+	// Фильтруем аргументы, чтобы игнорировать флаги go test
+	args := filterArgs(os.Args[1:]) // Игнорируем первый аргумент (имя программы)
+
+	// Значения по умолчанию, переопределенные файлом конфигурации
+	defaults := Config{
+		ServerAddr:    defaultServerAddr,
+		StoreInterval: defaultStoreInterval,
+		FileStorage:   defaultFileStorage,
+		Restore:       defaultRestore,
+		DatabaseDSN:   defaultDatabaseDSN,
+		Key:           defaultKey,
+		CryptoKey:     defaultCryptoKey,
+		GRPCAddr:      defaultGRPCAddr,
+		TrustedSubnet: defaultTrustedSubnet,
+
+		HistoryRetention: defaultHistoryRetention,
+	}
+	configPath := configfile.Path(args)
+	if configPath != "" {
+		if err := loadFile(configPath, &defaults); err != nil {
+			fmt.Println(fmt.Errorf("ошибка при чтении файла конфигурации: %w", err))
+			os.Exit(1)
+		}
+	}
+
+	// Получаем значения из переменных окружения или используем значения из файла и по умолчанию
+	serverAddr := getEnvOrDefault("ADDRESS", defaults.ServerAddr)
+	storeInterval := parseDuration("STORE_INTERVAL", getEnvOrDefault("STORE_INTERVAL", defaults.StoreInterval.String()), defaultStoreInterval)
+	fileStorage := getEnvOrDefault("FILE_STORAGE_PATH", defaults.FileStorage)
+	restore := parseBool(getEnvOrDefault("RESTORE", strconv.FormatBool(defaults.Restore)))
+	databaseDSN := getEnvOrDefault("DATABASE_DSN", defaults.DatabaseDSN)
+	key := getEnvOrDefault("KEY", defaults.Key)
+	cryptoKey := getEnvOrDefault("CRYPTO_KEY", defaults.CryptoKey)
+	grpcAddr := getEnvOrDefault("GRPC_ADDRESS", defaults.GRPCAddr)
+	trustedSubnet := getEnvOrDefault("TRUSTED_SUBNET", defaults.TrustedSubnet)
+	historyRetention := parseDuration("HISTORY_RETENTION", getEnvOrDefault("HISTORY_RETENTION", defaults.HistoryRetention.String()), defaultHistoryRetention)
 
 	// Используем локальный FlagSet для изоляции флагов
 	fs := flag.NewFlagSet("config", flag.ContinueOnError)
@@ -47,9 +87,13 @@ func NewConfig() *Config {
 	fs.BoolVar(&cfg.Restore, "r", restore, "Загружать данные при старте")
 	fs.StringVar(&cfg.DatabaseDSN, "d", databaseDSN, "DSN")
 	fs.StringVar(&cfg.Key, "k", key, "Ключ для подписи данных")
-
-	// Фильтруем аргументы, чтобы игнорировать флаги go test
-	args := filterArgs(os.Args[1:]) // Игнорируем первый аргумент (имя программы)
+	fs.StringVar(&cfg.CryptoKey, "crypto-key", cryptoKey, "Путь к файлу с приватным ключом")
+	fs.StringVar(&cfg.GRPCAddr, "g", grpcAddr, "Адрес gRPC-сервера")
+	fs.StringVar(&cfg.TrustedSubnet, "t", trustedSubnet, "Доверенная подсеть в формате CIDR")
+	fs.DurationVar(&cfg.HistoryRetention, "history-retention", historyRetention, "Срок хранения истории значений метрик, 0 — без ограничения")
+	// Путь к файлу уже определен configfile.Path, флаги регистрируются для корректного разбора
+	fs.String("c", configPath, "Путь к файлу конфигурации (JSON или YAML)")
+	fs.String("config", configPath, "Путь к файлу конфигурации (JSON или YAML)")
 
 	// Парсим только отфильтрованные аргументы
 	if err := fs.Parse(args); err != nil {
@@ -63,6 +107,10 @@ func NewConfig() *Config {
 		fs.Usage()
 		os.Exit(1)
 	}
+	if cfg.HistoryRetention < 0 {
+		fmt.Println("ошибка: срок хранения истории не может быть отрицательным:", cfg.HistoryRetention)
+		os.Exit(1)
+	}
 
 	return cfg
 }
@@ -85,7 +133,9 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-func parseDuration(value string) time.Duration {
+// parseDuration разбирает интервал из переменной окружения name; при ошибке
+// возвращается значение по умолчанию def.
+func parseDuration(name, value string, def time.Duration) time.Duration {
 	dur, err := time.ParseDuration(value)
 	if err == nil {
 		return dur
@@ -97,8 +147,8 @@ func parseDuration(value string) time.Duration {
 		return time.Duration(sec) * time.Second
 	}
 
-	log.Printf("Warning: Invalid STORE_INTERVAL value: %s, using default %v", value, defaultStoreInterval)
-	return defaultStoreInterval
+	log.Printf("Warning: Invalid %s value: %s, using default %v", name, value, def)
+	return def
 }
 
 func parseBool(value string) bool {
@@ -0,0 +1,51 @@
+package config
+
+import "go-metrics-server/internal/configfile"
+
+// fileConfig описывает файл конфигурации сервера. Незаданные в файле
+// поля остаются nil и не переопределяют значения по умолчанию.
+type fileConfig struct {
+	Address       *string              `json:"address" yaml:"address"`
+	StoreInterval *configfile.Duration `json:"store_interval" yaml:"store_interval"`
+	StoreFile     *string              `json:"store_file" yaml:"store_file"`
+	Restore       *bool                `json:"restore" yaml:"restore"`
+	DatabaseDSN   *string              `json:"database_dsn" yaml:"database_dsn"`
+	Key           *string              `json:"key" yaml:"key"`
+	CryptoKey     *string              `json:"crypto_key" yaml:"crypto_key"`
+	GRPCAddress   *string              `json:"grpc_address" yaml:"grpc_address"`
+	TrustedSubnet *string              `json:"trusted_subnet" yaml:"trusted_subnet"`
+
+	HistoryRetention *configfile.Duration `json:"history_retention" yaml:"history_retention"`
+}
+
+// loadFile читает файл конфигурации и переносит заданные в нем значения в cfg.
+func loadFile(path string, cfg *Config) error {
+	var f fileConfig
+	if err := configfile.Load(path, &f); err != nil {
+		return err
+	}
+
+	setString(&cfg.ServerAddr, f.Address)
+	if f.StoreInterval != nil {
+		cfg.StoreInterval = f.StoreInterval.Duration
+	}
+	setString(&cfg.FileStorage, f.StoreFile)
+	if f.Restore != nil {
+		cfg.Restore = *f.Restore
+	}
+	setString(&cfg.DatabaseDSN, f.DatabaseDSN)
+	setString(&cfg.Key, f.Key)
+	setString(&cfg.CryptoKey, f.CryptoKey)
+	setString(&cfg.GRPCAddr, f.GRPCAddress)
+	setString(&cfg.TrustedSubnet, f.TrustedSubnet)
+	if f.HistoryRetention != nil {
+		cfg.HistoryRetention = f.HistoryRetention.Duration
+	}
+	return nil
+}
+
+func setString(dst, src *string) {
+	if src != nil {
+		*dst = *src
+	}
+}
@@ -0,0 +1,257 @@
+// Package grpcserver реализует gRPC-транспорт сервера метрик поверх того же
+// service.MetricService, что и HTTP-обработчики.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/models"
+	pb "go-metrics-server/internal/proto"
+	"go-metrics-server/internal/server/config"
+	"go-metrics-server/internal/server/middleware"
+	"go-metrics-server/internal/server/repository"
+	"go-metrics-server/internal/server/service"
+	"io"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetricsServer реализует сервис pb.MetricsServer.
+type MetricsServer struct {
+	pb.UnimplementedMetricsServer
+	service *service.MetricService
+}
+
+func NewMetricsServer(service *service.MetricService) *MetricsServer {
+	return &MetricsServer{service: service}
+}
+
+// NewServer создает gRPC-сервер с зарегистрированным сервисом метрик.
+// Как и в HTTP-сервере, запись метрик разрешена только из доверенной подсети,
+// а при заданном ключе запросы без верной подписи отклоняются.
+func NewServer(cfg *config.Config, repo repository.MetricRepository) (*grpc.Server, error) {
+	var trustedSubnet *net.IPNet
+	if cfg.TrustedSubnet != "" {
+		_, subnet, err := net.ParseCIDR(cfg.TrustedSubnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted subnet: %w", err)
+		}
+		trustedSubnet = subnet
+	}
+
+	srv := grpc.NewServer(
+		grpc.ChainStreamInterceptor(trustedSubnetInterceptor(trustedSubnet), hashStreamInterceptor(cfg.Key)),
+		grpc.UnaryInterceptor(hashUnaryInterceptor(cfg.Key)),
+	)
+	pb.RegisterMetricsServer(srv, NewMetricsServer(service.NewMetricService(repo)))
+	return srv, nil
+}
+
+// trustedSubnetInterceptor отклоняет запись метрик, если адрес из метаданных
+// x-real-ip не входит в подсеть subnet.
+func trustedSubnetInterceptor(subnet *net.IPNet) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if subnet != nil && info.FullMethod == pb.Metrics_UpdateMetrics_FullMethodName {
+			ip := net.ParseIP(metadataValue(ss.Context(), middleware.RealIPHeader))
+			if ip == nil || !subnet.Contains(ip) {
+				return status.Error(codes.PermissionDenied, "address is not in trusted subnet")
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// hashUnaryInterceptor проверяет подпись запроса из метаданных hashsha256.
+func hashUnaryInterceptor(key string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if key == "" {
+			return handler(ctx, req)
+		}
+		hash := metadataValue(ctx, pb.HashMetadata)
+		if hash == "" {
+			return nil, errMissingHash
+		}
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return nil, status.Error(codes.Internal, "unsupported request type")
+		}
+		signer := pb.NewSigner(key)
+		if err := signer.Add(msg); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if !signer.Valid(hash) {
+			return nil, errInvalidHash
+		}
+		return handler(ctx, req)
+	}
+}
+
+// hashStreamInterceptor проверяет подпись потока из метаданных hashsha256.
+// Подпись считается по мере чтения сообщений и сверяется, когда клиент
+// закрывает поток, поэтому обработчик получает ошибку вместо io.EOF
+// и не сохраняет пакет с неверной подписью.
+func hashStreamInterceptor(key string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if key == "" {
+			return handler(srv, ss)
+		}
+		hash := metadataValue(ss.Context(), pb.HashMetadata)
+		if hash == "" {
+			return errMissingHash
+		}
+		return handler(srv, &signedStream{ServerStream: ss, signer: pb.NewSigner(key), hash: hash})
+	}
+}
+
+var (
+	errMissingHash = status.Error(codes.Unauthenticated, "missing hashsha256 metadata")
+	errInvalidHash = status.Error(codes.Unauthenticated, "invalid hashsha256 signature")
+)
+
+// signedStream считает подпись принятых сообщений потока.
+type signedStream struct {
+	grpc.ServerStream
+	signer *pb.Signer
+	hash   string
+}
+
+func (s *signedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if errors.Is(err, io.EOF) {
+		if !s.signer.Valid(s.hash) {
+			return errInvalidHash
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return status.Error(codes.Internal, "unsupported message type")
+	}
+	if err := s.signer.Add(msg); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// UpdateMetrics читает поток метрик до его закрытия клиентом и сохраняет
+// весь пакет одним вызовом, так же как POST /updates/.
+func (s *MetricsServer) UpdateMetrics(stream pb.Metrics_UpdateMetricsServer) error {
+	var metrics []models.Metrics
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		metric, err := pb.ToModel(msg)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		metrics = append(metrics, metric)
+	}
+
+	if len(metrics) == 0 {
+		return status.Error(codes.InvalidArgument, "empty metrics batch")
+	}
+	models.SetSource(metrics, agentID(stream.Context()))
+
+	resp := &pb.UpdateMetricsResponse{Accepted: uint32(len(metrics))}
+	key := metadataValue(stream.Context(), models.IdempotencyKeyHeader)
+	if key == "" {
+		if err := s.service.UpdateMetrics(stream.Context(), metrics); err != nil {
+			return statusError(err)
+		}
+		return stream.SendAndClose(resp)
+	}
+
+	// Повтор пакета с тем же ключом не применяется, клиент получает исходный ответ
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	stored, duplicate, err := s.service.UpdateMetricsIdempotent(stream.Context(), key, metrics, data)
+	if err != nil {
+		return statusError(err)
+	}
+	if duplicate {
+		resp = &pb.UpdateMetricsResponse{}
+		if err := proto.Unmarshal(stored, resp); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	return stream.SendAndClose(resp)
+}
+
+// GetMetric возвращает метрику с фильтром по меткам и объединением рядов, как POST /value/.
+func (s *MetricsServer) GetMetric(ctx context.Context, req *pb.GetMetricRequest) (*pb.Metric, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "metric name is required")
+	}
+	switch req.GetType() {
+	case "gauge", "counter", "histogram", "summary":
+	default:
+		return nil, status.Error(codes.InvalidArgument, "invalid metric type")
+	}
+
+	metric, err := s.service.AggregateMetrics(ctx, req.GetType(), req.GetId(), req.GetLabels(), req.GetAgg())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return pb.FromModel(metric), nil
+}
+
+func (s *MetricsServer) ListMetrics(ctx context.Context, _ *pb.ListMetricsRequest) (*pb.ListMetricsResponse, error) {
+	metrics, err := s.service.ListMetrics(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &pb.ListMetricsResponse{Metrics: make([]*pb.Metric, 0, len(metrics))}
+	for _, metric := range metrics {
+		resp.Metrics = append(resp.Metrics, pb.FromModel(metric))
+	}
+	return resp, nil
+}
+
+// agentID возвращает идентификатор агента из метаданных запроса.
+func agentID(ctx context.Context) string {
+	return metadataValue(ctx, models.AgentIDHeader)
+}
+
+// metadataValue возвращает первое значение ключа метаданных запроса.
+// Ключи метаданных gRPC передаются в нижнем регистре.
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(strings.ToLower(key)); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// statusError преобразует ошибку сервиса в gRPC-статус.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrMetricNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidAggregation), errors.Is(err, models.ErrBucketsMismatch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,157 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"go-metrics-server/internal/agent/sender"
+	"go-metrics-server/internal/models"
+	pb "go-metrics-server/internal/proto"
+	"go-metrics-server/internal/server/config"
+	"go-metrics-server/internal/server/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startServer запускает gRPC-сервер на свободном порту и возвращает его адрес.
+func startServer(t *testing.T, cfg *config.Config, repo repository.MetricRepository) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv, err := NewServer(cfg, repo)
+	require.NoError(t, err)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	return listener.Addr().String()
+}
+
+func TestMetricsServer(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	addr := startServer(t, &config.Config{}, repo)
+
+	s, err := sender.NewGRPC(addr)
+	require.NoError(t, err)
+	defer s.Close()
+	s.AgentID = "host-a"
+
+	err = s.SendMetricsBatch(context.Background(), map[string]interface{}{
+		"Alloc":     12.5,
+		"PollCount": int64(3),
+	})
+	require.NoError(t, err)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+	ctx := context.Background()
+
+	t.Run("get metric", func(t *testing.T) {
+		metric, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Alloc", Type: "gauge"})
+		require.NoError(t, err)
+		assert.Equal(t, 12.5, metric.GetValue())
+		assert.Equal(t, "host-a", metric.GetLabels()[models.SourceLabel])
+	})
+
+	t.Run("list metrics", func(t *testing.T) {
+		resp, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+		require.NoError(t, err)
+		assert.Len(t, resp.GetMetrics(), 2)
+	})
+
+	t.Run("metric not found", func(t *testing.T) {
+		_, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Missing", Type: "gauge"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("invalid metric in batch", func(t *testing.T) {
+		stream, err := client.UpdateMetrics(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.Metric{Id: "Alloc", Type: "gauge"}))
+		_, err = stream.CloseAndRecv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestTrustedSubnetInterceptor(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	addr := startServer(t, &config.Config{TrustedSubnet: "10.0.0.0/8"}, repo)
+
+	s, err := sender.NewGRPC(addr)
+	require.NoError(t, err)
+	defer s.Close()
+
+	err = s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+	assert.Equal(t, codes.PermissionDenied, status.Code(errors.Unwrap(err)))
+
+	s.RealIP = "10.1.1.1"
+	err = s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+	assert.NoError(t, err)
+}
+
+func TestUpdateMetricsIdempotency(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	addr := startServer(t, &config.Config{}, repo)
+
+	s, err := sender.NewGRPC(addr)
+	require.NoError(t, err)
+	defer s.Close()
+
+	delta := int64(2)
+	batch := models.Batch{ID: "batch-1", Metrics: []models.Metrics{{ID: "PollCount", MType: "counter", Delta: &delta}}}
+	require.NoError(t, s.SendBatch(context.Background(), batch))
+	// Повтор возвращает исходный ответ и не увеличивает счетчик
+	require.NoError(t, s.SendBatch(context.Background(), batch))
+
+	value, err := repo.GetCounter(context.Background(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), value)
+}
+
+func TestHashInterceptors(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	addr := startServer(t, &config.Config{Key: "secret"}, repo)
+
+	s, err := sender.NewGRPC(addr)
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Без подписи и с подписью другим ключом пакет не сохраняется
+	err = s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+	assert.Equal(t, codes.Unauthenticated, status.Code(errors.Unwrap(err)))
+	s.Key = "other"
+	err = s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+	assert.Equal(t, codes.Unauthenticated, status.Code(errors.Unwrap(err)))
+	_, err = repo.GetGauge(context.Background(), "Alloc")
+	assert.Error(t, err)
+
+	s.Key = "secret"
+	require.NoError(t, s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0, "PollCount": int64(1)}))
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	req := &pb.GetMetricRequest{Id: "Alloc", Type: "gauge"}
+	_, err = client.GetMetric(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	signer := pb.NewSigner("secret")
+	require.NoError(t, signer.Add(req))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), pb.HashMetadata, signer.Sum())
+	metric, err := client.GetMetric(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, metric.GetValue())
+}
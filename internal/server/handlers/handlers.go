@@ -3,10 +3,15 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go-metrics-server/internal/models"
 	"go-metrics-server/internal/server/service"
+	"html"
+	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -62,26 +67,48 @@ func (h *MetricHandler) UpdateMetric(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// GetMetricValue отдает значение метрики в текстовом виде.
+// Параметры запроса, кроме agg, задают фильтр по меткам; если под фильтр
+// подходит несколько рядов, они объединяются функцией agg (см. AggregateMetrics).
 func (h *MetricHandler) GetMetricValue(w http.ResponseWriter, r *http.Request) {
 	metricType := chi.URLParam(r, "type")
 	metricName := chi.URLParam(r, "name")
 
-	var value interface{}
-	var err error
-
-	ctx := r.Context()
 	switch metricType {
-	case "gauge":
-		value, err = h.service.GetGauge(ctx, metricName)
-	case "counter":
-		value, err = h.service.GetCounter(ctx, metricName)
+	case "gauge", "counter", "histogram", "summary":
 	default:
 		http.Error(w, "Invalid metric type", http.StatusBadRequest)
 		return
 	}
 
+	query := r.URL.Query()
+	metric, err := h.service.AggregateMetrics(r.Context(), metricType, metricName, labelsFromQuery(query, "agg"), query.Get("agg"))
 	if err != nil {
-		http.Error(w, "Metric not found", http.StatusNotFound)
+		http.Error(w, "Metric not found", lookupErrorStatus(err))
+		return
+	}
+
+	var value interface{}
+	switch {
+	case metric.Value != nil:
+		value = *metric.Value
+	case metric.Delta != nil:
+		value = *metric.Delta
+	default:
+		// Составные значения отдаем в виде JSON
+		var data []byte
+		if metric.Histogram != nil {
+			data, err = json.Marshal(metric.Histogram)
+		} else {
+			data, err = json.Marshal(metric.Summary)
+		}
+		if err != nil {
+			http.Error(w, "Failed to encode value", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
 		return
 	}
 
@@ -102,7 +129,7 @@ func (h *MetricHandler) GetAllMetrics(w http.ResponseWriter, r *http.Request) {
 	var buf bytes.Buffer
 	buf.WriteString("<h1>Metrics</h1>\n<ul>\n")
 	for name, value := range metrics {
-		buf.WriteString(fmt.Sprintf("<li>%s: %v</li>", name, value))
+		buf.WriteString(fmt.Sprintf("<li>%s: %v</li>", html.EscapeString(name), html.EscapeString(fmt.Sprint(value))))
 	}
 	buf.WriteString("</ul>")
 
@@ -126,36 +153,48 @@ func (h *MetricHandler) UpdateMetricJSON(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	ctx := r.Context()
 	switch metric.MType {
 	case "gauge":
 		if metric.Value == nil {
 			http.Error(w, "Value is required for gauge", http.StatusBadRequest)
 			return
 		}
-		if err := h.service.UpdateGauge(ctx, metric.ID, *metric.Value); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		value, _ := h.service.GetGauge(ctx, metric.ID)
-		metric.Value = &value
-
 	case "counter":
 		if metric.Delta == nil {
 			http.Error(w, "Delta is required for counter", http.StatusBadRequest)
 			return
 		}
-		if err := h.service.UpdateCounter(ctx, metric.ID, *metric.Delta); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	case "histogram":
+		if metric.Histogram == nil {
+			http.Error(w, "Histogram is required for histogram", http.StatusBadRequest)
+			return
+		}
+	case "summary":
+		if metric.Summary == nil {
+			http.Error(w, "Summary is required for summary", http.StatusBadRequest)
 			return
 		}
-		value, _ := h.service.GetCounter(ctx, metric.ID)
-		metric.Delta = &value
-
 	default:
 		http.Error(w, "Invalid metric type", http.StatusBadRequest)
 		return
 	}
+	if err := models.ValidateMetric(metric); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batch := []models.Metrics{metric}
+	applySource(r, batch)
+	metric = batch[0]
+
+	ctx := r.Context()
+	if err := h.service.UpdateMetrics(ctx, batch); err != nil {
+		http.Error(w, err.Error(), updateErrorStatus(err))
+		return
+	}
+	if stored, err := h.service.GetMetric(ctx, metric.MType, metric.ID, metric.Labels); err == nil {
+		metric = stored
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Transfer-Encoding", "chunked")
@@ -184,25 +223,22 @@ func (h *MetricHandler) GetMetricValueJSON(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	ctx := r.Context()
-	var err error
 	switch metric.MType {
-	case "gauge":
-		value, e := h.service.GetGauge(ctx, metric.ID)
-		err = e
-		metric.Value = &value
-	case "counter":
-		value, e := h.service.GetCounter(ctx, metric.ID)
-		err = e
-		metric.Delta = &value
+	case "gauge", "counter", "histogram", "summary":
 	default:
 		http.Error(w, "Invalid metric type", http.StatusBadRequest)
 		return
 	}
 
+	// Метки в запросе задают фильтр, параметр agg — функцию объединения рядов
+	result, err := h.service.AggregateMetrics(r.Context(), metric.MType, metric.ID, metric.Labels, r.URL.Query().Get("agg"))
+	if err == nil {
+		metric = result
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(lookupErrorStatus(err))
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
@@ -215,31 +251,124 @@ func (h *MetricHandler) BatchUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var metrics []models.Metrics
-	if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	batch, err := decodeBatch(r.Body)
+	if err != nil {
+		rejectBatch(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	metrics := batch.Metrics
 
 	if len(metrics) == 0 {
-		http.Error(w, "Empty metrics batch", http.StatusBadRequest)
+		rejectBatch(w, "Empty metrics batch", http.StatusBadRequest)
 		return
 	}
 
 	for _, metric := range metrics {
 		if metric.ID == "" {
-			http.Error(w, "Metric name is required", http.StatusBadRequest)
+			rejectBatch(w, "Metric name is required", http.StatusBadRequest)
+			return
+		}
+		if err := models.ValidateMetric(metric); err != nil {
+			rejectBatch(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
 
+	applySource(r, metrics)
+
+	var response bytes.Buffer
+	if err := json.NewEncoder(&response).Encode(metrics); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	body := response.Bytes()
+
+	// Повтор пакета с тем же ключом не применяется, клиент получает исходный ответ.
+	// Заголовок Idempotency-Key имеет приоритет над идентификатором в теле.
 	ctx := r.Context()
-	if err := h.service.UpdateMetrics(ctx, metrics); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update metrics: %v", err), http.StatusInternalServerError)
+	key := r.Header.Get(models.IdempotencyKeyHeader)
+	if key == "" {
+		key = batch.ID
+	}
+	if key != "" {
+		body, _, err = h.service.UpdateMetricsIdempotent(ctx, key, metrics, body)
+	} else {
+		err = h.service.UpdateMetrics(ctx, metrics)
+	}
+	if err != nil {
+		status := updateErrorStatus(err)
+		if status == http.StatusBadRequest {
+			rejectBatch(w, fmt.Sprintf("Failed to update metrics: %v", err), status)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to update metrics: %v", err), status)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(metrics)
+	w.Write(body)
+}
+
+// decodeBatch читает пакет метрик: массив метрик или объект models.Batch
+// с идентификатором пакета.
+func decodeBatch(r io.Reader) (models.Batch, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return models.Batch{}, err
+	}
+
+	var batch models.Batch
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		err := json.Unmarshal(trimmed, &batch)
+		return batch, err
+	}
+	err := json.Unmarshal(raw, &batch.Metrics)
+	return batch, err
+}
+
+// updateErrorStatus возвращает HTTP-статус для ошибки обновления метрик:
+// несовместимые с сохраненными данные считаются ошибкой клиента.
+func updateErrorStatus(err error) int {
+	if errors.Is(err, models.ErrBucketsMismatch) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// rejectBatch отвечает ошибкой, отмечая пакет как отклоненный из-за его
+// содержимого: агент удаляет такой пакет из очереди, а не повторяет его.
+func rejectBatch(w http.ResponseWriter, message string, status int) {
+	w.Header().Set(models.BatchRejectedHeader, "true")
+	http.Error(w, message, status)
+}
+
+// applySource добавляет к метрикам метку источника из заголовка X-Agent-ID,
+// чтобы метрики разных агентов хранились отдельно.
+func applySource(r *http.Request, metrics []models.Metrics) {
+	models.SetSource(metrics, r.Header.Get(models.AgentIDHeader))
+}
+
+// lookupErrorStatus возвращает HTTP-статус для ошибки поиска метрики.
+func lookupErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidAggregation) || errors.Is(err, models.ErrBucketsMismatch) {
+		return http.StatusBadRequest
+	}
+	return http.StatusNotFound
+}
+
+// labelsFromQuery возвращает фильтр по меткам из параметров запроса,
+// пропуская зарезервированные параметры reserved.
+func labelsFromQuery(query url.Values, reserved ...string) map[string]string {
+	labels := make(map[string]string)
+	for key, values := range query {
+		if len(values) == 0 || slices.Contains(reserved, key) {
+			continue
+		}
+		labels[key] = values[0]
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
 }
@@ -220,3 +220,260 @@ func TestGetMetricValueJSONHandler(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
 }
+
+func TestHistogramJSONHandlers(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewMetricHandler(service.NewMetricService(repo))
+
+	t.Run("update histogram twice", func(t *testing.T) {
+		body := `{"id":"latency","type":"histogram","histogram":{"bounds":[0.1,1],"counts":[1,2,0],"sum":1.1,"count":3}}`
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("POST", "/update/", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			handler.UpdateMetricJSON(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		req := httptest.NewRequest("POST", "/value/", strings.NewReader(`{"id":"latency","type":"histogram"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.GetMetricValueJSON(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp models.Metrics
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if assert.NotNil(t, resp.Histogram) {
+			assert.Equal(t, []uint64{2, 4, 0}, resp.Histogram.Counts)
+			assert.Equal(t, uint64(6), resp.Histogram.Count)
+		}
+	})
+
+	t.Run("invalid histogram", func(t *testing.T) {
+		body := `[{"id":"latency","type":"histogram","histogram":{"bounds":[0.1,1],"counts":[1],"sum":1,"count":1}}]`
+		req := httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.BatchUpdate(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("buckets mismatch", func(t *testing.T) {
+		body := `[{"id":"latency","type":"histogram","histogram":{"bounds":[5],"counts":[1,0],"sum":1,"count":1}}]`
+		req := httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.BatchUpdate(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("text value", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/value/histogram/latency", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("type", "histogram")
+		rctx.URLParams.Add("name", "latency")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		w := httptest.NewRecorder()
+		handler.GetMetricValue(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"counts":[2,4,0]`)
+	})
+}
+
+func TestLabeledMetricsHandlers(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewMetricHandler(service.NewMetricService(repo))
+
+	body := `[
+		{"id":"cpu","type":"gauge","value":10,"labels":{"host":"a"}},
+		{"id":"cpu","type":"gauge","value":30,"labels":{"host":"b"}},
+		{"id":"requests","type":"counter","delta":2,"labels":{"host":"a"}},
+		{"id":"requests","type":"counter","delta":3,"labels":{"host":"b"}}
+	]`
+	req := httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.BatchUpdate(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	getValue := func(body, query string) (int, models.Metrics) {
+		req := httptest.NewRequest("POST", "/value/"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.GetMetricValueJSON(w, req)
+
+		var resp models.Metrics
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	t.Run("filter by label", func(t *testing.T) {
+		code, resp := getValue(`{"id":"cpu","type":"gauge","labels":{"host":"b"}}`, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 30.0, *resp.Value)
+		assert.Equal(t, map[string]string{"host": "b"}, resp.Labels)
+	})
+
+	t.Run("aggregate gauges", func(t *testing.T) {
+		code, resp := getValue(`{"id":"cpu","type":"gauge"}`, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 20.0, *resp.Value)
+
+		code, resp = getValue(`{"id":"cpu","type":"gauge"}`, "?agg=max")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 30.0, *resp.Value)
+	})
+
+	t.Run("aggregate counters", func(t *testing.T) {
+		code, resp := getValue(`{"id":"requests","type":"counter"}`, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, int64(5), *resp.Delta)
+	})
+
+	t.Run("invalid aggregation", func(t *testing.T) {
+		code, _ := getValue(`{"id":"requests","type":"counter"}`, "?agg=median")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("no matching series", func(t *testing.T) {
+		code, _ := getValue(`{"id":"cpu","type":"gauge","labels":{"host":"c"}}`, "")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("text value with label filter", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/value/gauge/cpu?host=a", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("type", "gauge")
+		rctx.URLParams.Add("name", "cpu")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		w := httptest.NewRecorder()
+		handler.GetMetricValue(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "10", w.Body.String())
+	})
+
+	t.Run("update with labels returns stored value", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/update/", strings.NewReader(`{"id":"requests","type":"counter","delta":1,"labels":{"host":"a"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.UpdateMetricJSON(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp models.Metrics
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, int64(3), *resp.Delta)
+	})
+}
+
+func TestSourcesHandlers(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewMetricHandler(service.NewMetricService(repo))
+
+	send := func(agentID, body string) {
+		req := httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(models.AgentIDHeader, agentID)
+		w := httptest.NewRecorder()
+		handler.BatchUpdate(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	send("host-a", `[{"id":"Alloc","type":"gauge","value":1},{"id":"PollCount","type":"counter","delta":5}]`)
+	send("host-b", `[{"id":"Alloc","type":"gauge","value":2}]`)
+
+	// Метрики одного имени от разных агентов хранятся раздельно
+	value, err := handler.service.GetMetric(context.Background(), "gauge", "Alloc", map[string]string{models.SourceLabel: "host-a"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, *value.Value)
+
+	req := httptest.NewRequest("GET", "/sources", nil)
+	w := httptest.NewRecorder()
+	handler.ListSources(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var sources []models.Source
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sources))
+	assert.Equal(t, []models.Source{{ID: "host-a", Metrics: 2}, {ID: "host-b", Metrics: 1}}, sources)
+
+	getSource := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/sources/"+id, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("source", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.GetSourceMetrics(w, req)
+		return w
+	}
+
+	w = getSource("host-b")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var metrics []models.Metrics
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "Alloc", metrics[0].ID)
+	assert.Equal(t, "host-b", metrics[0].Labels[models.SourceLabel])
+
+	w = getSource("unknown")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBatchUpdateIdempotency(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewMetricHandler(service.NewMetricService(repo))
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(models.IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		handler.BatchUpdate(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	first := send("batch-1", `[{"id":"PollCount","type":"counter","delta":5}]`)
+	// Повтор с тем же ключом не применяется и получает исходный ответ
+	retry := send("batch-1", `[{"id":"PollCount","type":"counter","delta":5}]`)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+
+	value, err := repo.GetCounter(context.Background(), "PollCount")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), value)
+
+	// Идентификатор пакета можно передать в теле запроса
+	send("", `{"id":"batch-2","metrics":[{"id":"PollCount","type":"counter","delta":1}]}`)
+	send("", `{"id":"batch-2","metrics":[{"id":"PollCount","type":"counter","delta":1}]}`)
+	// Пакеты без ключа применяются каждый раз
+	send("", `[{"id":"PollCount","type":"counter","delta":1}]`)
+	send("", `[{"id":"PollCount","type":"counter","delta":1}]`)
+
+	value, err = repo.GetCounter(context.Background(), "PollCount")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), value)
+}
+
+func TestBatchUpdateRejectedHeader(t *testing.T) {
+	handler := NewMetricHandler(service.NewMetricService(repository.NewMemoryRepository()))
+
+	send := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		handler.BatchUpdate(w, req)
+		return w
+	}
+
+	// Пакет отмечается отклоненным только из-за своего содержимого
+	for _, body := range []string{`not json`, `[]`, `[{"type":"gauge","value":1}]`, `[{"id":"a","type":"gauge","value":1,"labels":{"":"x"}}]`} {
+		w := send("application/json", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Equal(t, "true", w.Header().Get(models.BatchRejectedHeader), body)
+	}
+
+	w := send("text/plain", `[{"id":"a","type":"gauge","value":1}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get(models.BatchRejectedHeader))
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultHistoryRange = time.Hour
+	maxHistoryPoints    = 10000
+)
+
+// GetHistory отдает историю значений gauge или counter за интервал [from, to],
+// сгруппированную по интервалам step.
+// Параметры from и to принимаются в формате RFC 3339 или как Unix-время в секундах,
+// step — как длительность Go ("30s", "1m") или число секунд.
+// Остальные параметры запроса задают метки ряда.
+func (h *MetricHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	metricType := chi.URLParam(r, "type")
+	metricName := chi.URLParam(r, "name")
+
+	if metricType != "gauge" && metricType != "counter" {
+		http.Error(w, "Invalid metric type", http.StatusBadRequest)
+		return
+	}
+	if metricName == "" {
+		http.Error(w, "Metric name is required", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	if v := query.Get("to"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultHistoryRange)
+	if v := query.Get("from"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	var step time.Duration
+	if v := query.Get("step"); v != "" {
+		d, err := parseStep(v)
+		if err != nil {
+			http.Error(w, "Invalid step parameter", http.StatusBadRequest)
+			return
+		}
+		if to.Sub(from)/d > maxHistoryPoints {
+			http.Error(w, fmt.Sprintf("Too many points requested, max %d", maxHistoryPoints), http.StatusBadRequest)
+			return
+		}
+		step = d
+	}
+
+	labels := labelsFromQuery(query, "from", "to", "step")
+	points, err := h.service.GetHistory(r.Context(), metricType, metricName, labels, from, to, step)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(points)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func parseStep(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		sec, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, err
+		}
+		d = time.Duration(sec) * time.Second
+	}
+	if d <= 0 {
+		return 0, errors.New("step must be positive")
+	}
+	return d, nil
+}
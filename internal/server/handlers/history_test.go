@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-metrics-server/internal/models"
+	"go-metrics-server/internal/server/repository"
+	"go-metrics-server/internal/server/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHistoryHandler(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewMetricHandler(service.NewMetricService(repo))
+
+	ctx := context.Background()
+	repo.UpdateCounter(ctx, "PollCount", 1)
+	repo.UpdateCounter(ctx, "PollCount", 2)
+
+	request := func(metricType, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/history/"+metricType+"/PollCount"+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("type", metricType)
+		rctx.URLParams.Add("name", "PollCount")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		w := httptest.NewRecorder()
+		handler.GetHistory(w, req)
+		return w
+	}
+
+	t.Run("raw points", func(t *testing.T) {
+		w := request("counter", "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var points []models.HistoryPoint
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &points))
+		require.Len(t, points, 2)
+		assert.Equal(t, 1.0, points[0].Value)
+		assert.Equal(t, 3.0, points[1].Value)
+	})
+
+	t.Run("downsampled", func(t *testing.T) {
+		w := request("counter", "?step=1h")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var points []models.HistoryPoint
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &points))
+		require.Len(t, points, 1)
+		assert.Equal(t, 3.0, points[0].Value)
+	})
+
+	t.Run("empty history", func(t *testing.T) {
+		w := request("gauge", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, "[]", w.Body.String())
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, request("histogram", "").Code)
+		assert.Equal(t, http.StatusBadRequest, request("counter", "?step=-1s").Code)
+		assert.Equal(t, http.StatusBadRequest, request("counter", "?from=bad").Code)
+		assert.Equal(t, http.StatusBadRequest, request("counter", "?from=200&to=100").Code)
+	})
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"go-metrics-server/internal/prometheus"
+	"net/http"
+)
+
+// GetMetricsPrometheus отдает все метрики в текстовом формате Prometheus.
+func (h *MetricHandler) GetMetricsPrometheus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	metrics, err := h.service.ListMetrics(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get metrics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := prometheus.WriteText(&buf, metrics); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode metrics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", prometheus.ContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-metrics-server/internal/server/repository"
+	"go-metrics-server/internal/server/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMetricsPrometheus(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	handler := NewMetricHandler(service.NewMetricService(repo))
+
+	ctx := context.Background()
+	repo.UpdateGauge(ctx, "HeapAlloc", 1024.5)
+	repo.UpdateCounter(ctx, "PollCount", 3)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	handler.GetMetricsPrometheus(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE HeapAlloc gauge\nHeapAlloc 1024.5\n")
+	assert.Contains(t, body, "# TYPE PollCount counter\nPollCount 3\n")
+	assert.Contains(t, body, "# HELP PollCount ")
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-metrics-server/internal/server/service"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListSources отдает список агентов, от которых получены метрики.
+func (h *MetricHandler) ListSources(w http.ResponseWriter, r *http.Request) {
+	sources, err := h.service.ListSources(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get sources: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sources)
+}
+
+// GetSourceMetrics отдает все метрики, полученные от одного агента.
+func (h *MetricHandler) GetSourceMetrics(w http.ResponseWriter, r *http.Request) {
+	source := chi.URLParam(r, "source")
+
+	metrics, err := h.service.SourceMetrics(r.Context(), source)
+	if err != nil {
+		if errors.Is(err, service.ErrMetricNotFound) {
+			http.Error(w, "Source not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get metrics: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(metrics)
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"go-metrics-server/internal/encryption"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DecryptMiddleware создает middleware для расшифровки тела запросов агента.
+// Должна стоять раньше распаковки gzip и проверки подписи: агент сначала
+// подписывает и сжимает данные, а затем шифрует. Если ключ задан, все
+// незашифрованные запросы на запись метрик отклоняются, в том числе
+// POST /update/{type}/{name}/{value} без тела: значение в пути нельзя
+// зашифровать. Запросы на чтение (например, POST /value/) можно отправлять
+// без шифрования.
+func DecryptMiddleware(key *rsa.PrivateKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Header.Get(encryption.Header) == "" {
+				if isWriteRequest(r) {
+					http.Error(w, "Update requests must be encrypted", http.StatusBadRequest)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Header.Get(encryption.Header) != encryption.Scheme {
+				http.Error(w, "Unsupported encryption scheme", http.StatusBadRequest)
+				return
+			}
+
+			message, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			body, err := encryption.Decrypt(key, message)
+			if err != nil {
+				http.Error(w, "Failed to decrypt request body", http.StatusBadRequest)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			r.Header.Del(encryption.Header)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isWriteRequest сообщает, что запрос изменяет метрики: /update/, /updates/
+// и /update/{type}/{name}/{value}.
+func isWriteRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/update")
+}
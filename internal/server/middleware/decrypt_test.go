@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-metrics-server/internal/encryption"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptMiddleware(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// Обработчик возвращает полученное тело
+	handler := DecryptMiddleware(key)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+
+	t.Run("encrypted update", func(t *testing.T) {
+		message, err := encryption.Encrypt(&key.PublicKey, []byte(`{"id":"a"}`))
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(message))
+		req.Header.Set(encryption.Header, encryption.Scheme)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"id":"a"}`, rec.Body.String())
+	})
+
+	t.Run("plain update", func(t *testing.T) {
+		for _, path := range []string{"/update/", "/updates/", "/update/gauge/a/1"} {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"id":"a"}`))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+		}
+	})
+
+	t.Run("plain path update without body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/update/counter/a/5", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("plain value request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/value/", strings.NewReader(`{"id":"a","type":"gauge"}`))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"id":"a","type":"gauge"}`, rec.Body.String())
+	})
+
+	t.Run("unknown scheme", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("x"))
+		req.Header.Set(encryption.Header, "rot13")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -18,6 +18,7 @@ func GzipMiddleware(next http.Handler) http.Handler {
 			}
 			defer gz.Close()
 			r.Body = gz
+			r.Header.Del("Content-Encoding")
 		}
 
 		// Проверяем, нужно ли сжимать ответ
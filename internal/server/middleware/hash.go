@@ -11,7 +11,12 @@ import (
 	"strings"
 )
 
-// HashMiddleware создает middleware для проверки подписи запросов и подписи ответов
+// HashHeader — заголовок с HMAC-SHA256 подписью тела запроса или ответа.
+const HashHeader = "HashSHA256"
+
+// HashMiddleware создает middleware для проверки подписи запросов и подписи ответов.
+// Подпись считается по несжатому телу, поэтому middleware нужно ставить
+// после распаковки запросов и до сжатия ответов.
 func HashMiddleware(key string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -23,7 +28,7 @@ func HashMiddleware(key string) func(http.Handler) http.Handler {
 
 			// Для POST запросов проверяем подпись
 			if r.Method == http.MethodPost {
-				hash := r.Header.Get("HashSHA256")
+				hash := r.Header.Get(HashHeader)
 				if hash == "" {
 					http.Error(w, "Missing HashSHA256 header", http.StatusBadRequest)
 					return
@@ -46,51 +51,74 @@ func HashMiddleware(key string) func(http.Handler) http.Handler {
 						http.Error(w, "Failed to read decompressed body", http.StatusBadRequest)
 						return
 					}
-					// Заменяем тело для последующих обработчиков
-					r.Body = io.NopCloser(bytes.NewReader(body))
+					// Тело уже распаковано, последующим обработчикам распаковывать не нужно
+					r.Header.Del("Content-Encoding")
 				} else {
 					body, err = io.ReadAll(r.Body)
 					if err != nil {
 						http.Error(w, "Failed to read request body", http.StatusBadRequest)
 						return
 					}
-					r.Body = io.NopCloser(bytes.NewReader(body))
 				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
 
-				// Проверяем подпись
-				h := hmac.New(sha256.New, []byte(key))
-				h.Write(body)
-				expectedHash := hex.EncodeToString(h.Sum(nil))
-
-				if hash != expectedHash {
+				if !ValidHash(key, body, hash) {
 					http.Error(w, "Invalid HashSHA256", http.StatusBadRequest)
 					return
 				}
 			}
 
-			// Обертываем ResponseWriter для добавления подписи к ответу
+			// Ответ буферизуется целиком и подписывается после завершения обработчика
 			writer := &hashResponseWriter{
 				ResponseWriter: w,
-				key:            []byte(key),
+				statusCode:     http.StatusOK,
 			}
 			next.ServeHTTP(writer, r)
+
+			w.Header().Set(HashHeader, Sign(key, writer.body.Bytes()))
+			w.Header().Del("Content-Length")
+			w.WriteHeader(writer.statusCode)
+			w.Write(writer.body.Bytes())
 		})
 	}
 }
 
-// hashResponseWriter обертка для http.ResponseWriter, добавляющая подпись к ответу
+// Sign возвращает HMAC-SHA256 подпись данных в шестнадцатеричном виде.
+func Sign(key string, data []byte) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ValidHash проверяет подпись данных за постоянное время.
+func ValidHash(key string, data []byte, hash string) bool {
+	received, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hmac.Equal(received, h.Sum(nil))
+}
+
+// hashResponseWriter обертка для http.ResponseWriter, накапливающая ответ,
+// чтобы подпись считалась по всему телу, а не по отдельным вызовам Write.
 type hashResponseWriter struct {
 	http.ResponseWriter
-	key []byte
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
 }
 
-func (w *hashResponseWriter) Write(b []byte) (int, error) {
-	// Добавляем подпись, если ключ установлен
-	if len(w.key) > 0 {
-		h := hmac.New(sha256.New, w.key)
-		h.Write(b)
-		hash := hex.EncodeToString(h.Sum(nil))
-		w.Header().Set("HashSHA256", hash)
+func (w *hashResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
 	}
-	return w.ResponseWriter.Write(b)
+	w.statusCode = statusCode
+	w.wroteHeader = true
+}
+
+func (w *hashResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
 }
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashMiddleware(t *testing.T) {
+	const key = "secret"
+	handler := HashMiddleware(key)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("first "))
+		w.Write([]byte("second"))
+	}))
+
+	t.Run("response is signed over the whole body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "first second", rec.Body.String())
+		assert.Equal(t, Sign(key, []byte("first second")), rec.Header().Get(HashHeader))
+	})
+
+	t.Run("valid request signature", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data"))
+		req.Header.Set(HashHeader, Sign(key, []byte("data")))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("invalid request signature", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data"))
+		req.Header.Set(HashHeader, Sign("other", []byte("data")))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("malformed signature", func(t *testing.T) {
+		assert.False(t, ValidHash(key, []byte("data"), "not-hex"))
+	})
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+)
+
+// RealIPHeader — заголовок с IP-адресом агента, по которому проверяется
+// доверенная подсеть. Для gRPC адрес передается в метаданных x-real-ip.
+const RealIPHeader = "X-Real-IP"
+
+// TrustedSubnetMiddleware создает middleware, пропускающую только запросы,
+// у которых адрес из заголовка X-Real-IP входит в подсеть subnet.
+// Если подсеть не задана, проверка отключена.
+func TrustedSubnetMiddleware(subnet *net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subnet == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := net.ParseIP(r.Header.Get(RealIPHeader))
+			if ip == nil || !subnet.Contains(ip) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
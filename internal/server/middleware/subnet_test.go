@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedSubnetMiddleware(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("192.168.1.0/24")
+	assert.NoError(t, err)
+
+	handler := TrustedSubnetMiddleware(subnet)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		realIP string
+		want   int
+	}{
+		{"address in subnet", "192.168.1.10", http.StatusOK},
+		{"address outside subnet", "10.0.0.1", http.StatusForbidden},
+		{"missing header", "", http.StatusForbidden},
+		{"invalid address", "not-an-ip", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	t.Run("no subnet configured", func(t *testing.T) {
+		handler := TrustedSubnetMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"go-metrics-server/internal/models"
+	"time"
+)
+
+// historyCapacity — количество последних значений, хранимых в памяти для каждой метрики.
+const historyCapacity = 4096
+
+type historyKey struct {
+	mType  string
+	series seriesKey
+}
+
+// historyRing — кольцевой буфер значений одной метрики. Буфер растет
+// по мере добавления значений и начинает перезаписывать самые старые,
+// только достигнув capacity.
+type historyRing struct {
+	points   []models.HistoryPoint
+	capacity int
+	next     int // Позиция самого старого значения в заполненном буфере
+}
+
+func newHistoryRing(capacity int) *historyRing {
+	return &historyRing{capacity: capacity}
+}
+
+func (h *historyRing) add(point models.HistoryPoint) {
+	if len(h.points) < h.capacity {
+		h.points = append(h.points, point)
+		return
+	}
+	h.points[h.next] = point
+	h.next = (h.next + 1) % h.capacity
+}
+
+// rangeOf возвращает значения из интервала [from, to] в хронологическом порядке.
+func (h *historyRing) rangeOf(from, to time.Time) []models.HistoryPoint {
+	result := make([]models.HistoryPoint, 0, len(h.points))
+	for _, p := range h.ordered() {
+		if p.Timestamp.Before(from) || p.Timestamp.After(to) {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// ordered возвращает значения в хронологическом порядке.
+func (h *historyRing) ordered() []models.HistoryPoint {
+	ordered := make([]models.HistoryPoint, 0, len(h.points))
+	ordered = append(ordered, h.points[h.next:]...)
+	return append(ordered, h.points[:h.next]...)
+}
+
+// prune удаляет значения, записанные раньше before, и возвращает их число.
+func (h *historyRing) prune(before time.Time) int {
+	kept := make([]models.HistoryPoint, 0, len(h.points))
+	for _, p := range h.ordered() {
+		if !p.Timestamp.Before(before) {
+			kept = append(kept, p)
+		}
+	}
+	pruned := len(h.points) - len(kept)
+	if pruned > 0 {
+		h.points = kept
+		h.next = 0
+	}
+	return pruned
+}
+
+// downsample группирует значения по интервалам длительностью step, отсчитываемым от from.
+// Для gauge точкой интервала становится среднее значение, для counter — последнее.
+// При step <= 0 значения возвращаются без изменений.
+func downsample(points []models.HistoryPoint, mType string, from time.Time, step time.Duration) []models.HistoryPoint {
+	if step <= 0 {
+		return points
+	}
+
+	result := make([]models.HistoryPoint, 0)
+	var sum float64
+	var n int
+	for i, p := range points {
+		bucket := from.Add(p.Timestamp.Sub(from) / step * step)
+		if n > 0 && !bucket.Equal(result[len(result)-1].Timestamp) {
+			n, sum = 0, 0
+		}
+		if n == 0 {
+			result = append(result, models.HistoryPoint{Timestamp: bucket})
+		}
+		n++
+		sum += p.Value
+
+		last := &result[len(result)-1]
+		if mType == "counter" {
+			last.Value = points[i].Value
+		} else {
+			last.Value = sum / float64(n)
+		}
+	}
+	return result
+}
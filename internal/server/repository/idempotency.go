@@ -0,0 +1,59 @@
+package repository
+
+import "time"
+
+const (
+	// idempotencyTTL — время, в течение которого повтор пакета с тем же ключом
+	// не применяется повторно.
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyCapacity — максимальное число хранимых ключей.
+	idempotencyCapacity = 10000
+)
+
+type idempotencyRecord struct {
+	response []byte
+	created  time.Time
+}
+
+// idempotencyStore хранит ответы на примененные пакеты по ключу идемпотентности.
+// Записи удаляются по истечении idempotencyTTL, а при превышении
+// idempotencyCapacity — начиная с самых старых.
+type idempotencyStore struct {
+	records map[string]idempotencyRecord
+	order   []string // Ключи в порядке добавления
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{records: make(map[string]idempotencyRecord)}
+}
+
+// get возвращает сохраненный ответ, если ключ известен и не устарел.
+func (s *idempotencyStore) get(key string, now time.Time) ([]byte, bool) {
+	record, ok := s.records[key]
+	if !ok || now.Sub(record.created) > idempotencyTTL {
+		return nil, false
+	}
+	return record.response, true
+}
+
+func (s *idempotencyStore) put(key string, response []byte, now time.Time) {
+	if _, ok := s.records[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.records[key] = idempotencyRecord{response: response, created: now}
+	s.evict(now)
+}
+
+// evict удаляет устаревшие записи и записи сверх лимита.
+func (s *idempotencyStore) evict(now time.Time) {
+	n := 0
+	for n < len(s.order) {
+		record := s.records[s.order[n]]
+		if len(s.order)-n <= idempotencyCapacity && now.Sub(record.created) <= idempotencyTTL {
+			break
+		}
+		delete(s.records, s.order[n])
+		n++
+	}
+	s.order = s.order[n:]
+}
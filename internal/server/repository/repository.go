@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"go-metrics-server/internal/models"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,13 +21,30 @@ const (
 	retryDelay = time.Second
 )
 
+// metricTypes — поддерживаемые типы метрик в порядке вывода.
+var metricTypes = []string{"gauge", "counter", "histogram", "summary"}
+
 type MetricRepository interface {
 	UpdateGauge(ctx context.Context, name string, value float64) error
 	UpdateCounter(ctx context.Context, name string, value int64) error
 	GetGauge(ctx context.Context, name string) (float64, error)
 	GetCounter(ctx context.Context, name string) (int64, error)
+	UpdateHistogram(ctx context.Context, name string, value models.Histogram) error
+	GetHistogram(ctx context.Context, name string) (models.Histogram, error)
+	UpdateSummary(ctx context.Context, name string, value models.Summary) error
+	GetSummary(ctx context.Context, name string) (models.Summary, error)
 	GetAllMetrics(ctx context.Context) (map[string]interface{}, error)
+	ListMetrics(ctx context.Context) ([]models.Metrics, error)
+	FindMetrics(ctx context.Context, mType, name string, filter map[string]string) ([]models.Metrics, error)
+	GetHistory(ctx context.Context, mType, name string, labels map[string]string, from, to time.Time, step time.Duration) ([]models.HistoryPoint, error)
+	// PruneHistory удаляет значения истории, записанные раньше before,
+	// и возвращает число удаленных значений.
+	PruneHistory(ctx context.Context, before time.Time) (int64, error)
 	UpdateMetrics(ctx context.Context, metrics []models.Metrics) error
+	// UpdateMetricsIdempotent применяет пакет один раз для ключа key и запоминает
+	// ответ response. Для уже примененного ключа пакет повторно не применяется:
+	// возвращается сохраненный ответ и duplicate = true.
+	UpdateMetricsIdempotent(ctx context.Context, key string, metrics []models.Metrics, response []byte) (stored []byte, duplicate bool, err error)
 	SaveToFile(ctx context.Context, filename string) error
 	LoadFromFile(ctx context.Context, filename string) error
 }
@@ -46,8 +64,10 @@ func NewPostgresRepository(db *sql.DB) (*PostgresRepository, error) {
 func (r *PostgresRepository) createTables(ctx context.Context) error {
 	_, err := r.db.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS gauges (
-			name TEXT PRIMARY KEY,
-			value DOUBLE PRECISION NOT NULL
+			name TEXT NOT NULL,
+			labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+			value DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (name, labels)
 		)
 	`)
 	if err != nil {
@@ -56,13 +76,130 @@ func (r *PostgresRepository) createTables(ctx context.Context) error {
 
 	_, err = r.db.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS counters (
-			name TEXT PRIMARY KEY,
-			value BIGINT NOT NULL
+			name TEXT NOT NULL,
+			labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+			value BIGINT NOT NULL,
+			PRIMARY KEY (name, labels)
 		)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to create counters table: %w", err)
 	}
+
+	_, err = r.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS histograms (
+			name TEXT NOT NULL,
+			labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+			data JSONB NOT NULL,
+			PRIMARY KEY (name, labels)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create histograms table: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS summaries (
+			name TEXT NOT NULL,
+			labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+			data JSONB NOT NULL,
+			PRIMARY KEY (name, labels)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create summaries table: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS metric_history (
+			mtype TEXT NOT NULL,
+			name TEXT NOT NULL,
+			labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+			ts TIMESTAMPTZ NOT NULL DEFAULT now(),
+			value DOUBLE PRECISION NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create metric_history table: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			key TEXT PRIMARY KEY,
+			response BYTEA NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency_keys table: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		CREATE INDEX IF NOT EXISTS idempotency_keys_created_idx
+		ON idempotency_keys (created_at)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency_keys index: %w", err)
+	}
+
+	if err := r.migrateLabels(ctx); err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		CREATE INDEX IF NOT EXISTS metric_history_series_idx
+		ON metric_history (mtype, name, labels, ts)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create metric_history index: %w", err)
+	}
+
+	// Индекс по времени нужен для удаления устаревшей истории
+	_, err = r.db.ExecContext(ctx, `
+		CREATE INDEX IF NOT EXISTS metric_history_ts_idx
+		ON metric_history (ts)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create metric_history index: %w", err)
+	}
+	return nil
+}
+
+// migrateLabels добавляет колонку labels в таблицы, созданные до появления меток,
+// переносит первичный ключ с name на (name, labels) и удаляет индекс истории
+// без меток: его заменяет metric_history_series_idx.
+func (r *PostgresRepository) migrateLabels(ctx context.Context) error {
+	for _, table := range []string{"gauges", "counters", "histograms", "summaries", "metric_history"} {
+		_, err := r.db.ExecContext(ctx, fmt.Sprintf(`
+			ALTER TABLE %s ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb
+		`, table))
+		if err != nil {
+			return fmt.Errorf("failed to add labels to %s: %w", table, err)
+		}
+		if table == "metric_history" {
+			_, err = r.db.ExecContext(ctx, `DROP INDEX IF EXISTS metric_history_lookup_idx`)
+			if err != nil {
+				return fmt.Errorf("failed to drop metric_history index: %w", err)
+			}
+			continue
+		}
+
+		_, err = r.db.ExecContext(ctx, fmt.Sprintf(`
+			DO $$
+			BEGIN
+				IF EXISTS (
+					SELECT 1 FROM pg_index
+					WHERE indrelid = '%[1]s'::regclass AND indisprimary AND indnatts = 1
+				) THEN
+					ALTER TABLE %[1]s DROP CONSTRAINT %[1]s_pkey;
+					ALTER TABLE %[1]s ADD PRIMARY KEY (name, labels);
+				END IF;
+			END $$
+		`, table))
+		if err != nil {
+			return fmt.Errorf("failed to migrate primary key of %s: %w", table, err)
+		}
+	}
 	return nil
 }
 
@@ -90,29 +227,76 @@ func (r *PostgresRepository) createTablesWithRetry(ctx context.Context) error {
 }
 
 func (r *PostgresRepository) UpdateGauge(ctx context.Context, name string, value float64) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO gauges (name, value)
-		VALUES ($1, $2)
-		ON CONFLICT (name)
-		DO UPDATE SET value = EXCLUDED.value
-	`, name, value)
-	return err
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := upsertGauge(ctx, tx, name, nil, value); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *PostgresRepository) UpdateCounter(ctx context.Context, name string, value int64) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO counters (name, value)
-		VALUES ($1, $2)
-		ON CONFLICT (name)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := upsertCounter(ctx, tx, name, nil, value); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// upsertGauge сохраняет значение gauge и добавляет его в историю.
+func upsertGauge(ctx context.Context, tx *sql.Tx, name string, labels map[string]string, value float64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO gauges (name, labels, value)
+		VALUES ($1, $2::jsonb, $3)
+		ON CONFLICT (name, labels)
+		DO UPDATE SET value = EXCLUDED.value
+	`, name, labelsJSON(labels), value)
+	if err != nil {
+		return fmt.Errorf("failed to update gauge %s: %w", name, err)
+	}
+	return appendHistory(ctx, tx, "gauge", name, labels, value)
+}
+
+// upsertCounter увеличивает counter и добавляет в историю новое накопленное значение.
+func upsertCounter(ctx context.Context, tx *sql.Tx, name string, labels map[string]string, delta int64) error {
+	var total int64
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO counters (name, labels, value)
+		VALUES ($1, $2::jsonb, $3)
+		ON CONFLICT (name, labels)
 		DO UPDATE SET value = counters.value + EXCLUDED.value
-	`, name, value)
-	return err
+		RETURNING value
+	`, name, labelsJSON(labels), delta).Scan(&total)
+	if err != nil {
+		return fmt.Errorf("failed to update counter %s: %w", name, err)
+	}
+	return appendHistory(ctx, tx, "counter", name, labels, float64(total))
+}
+
+func appendHistory(ctx context.Context, tx *sql.Tx, mType, name string, labels map[string]string, value float64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO metric_history (mtype, name, labels, value)
+		VALUES ($1, $2, $3::jsonb, $4)
+	`, mType, name, labelsJSON(labels), value)
+	if err != nil {
+		return fmt.Errorf("failed to append history for %s: %w", name, err)
+	}
+	return nil
 }
 
 func (r *PostgresRepository) GetGauge(ctx context.Context, name string) (float64, error) {
 	var value float64
 	err := r.db.QueryRowContext(ctx, `
-		SELECT value FROM gauges WHERE name = $1
+		SELECT value FROM gauges WHERE name = $1 AND labels = '{}'::jsonb
 	`, name).Scan(&value)
 
 	if err != nil {
@@ -127,7 +311,7 @@ func (r *PostgresRepository) GetGauge(ctx context.Context, name string) (float64
 func (r *PostgresRepository) GetCounter(ctx context.Context, name string) (int64, error) {
 	var value int64
 	err := r.db.QueryRowContext(ctx, `
-		SELECT value FROM counters WHERE name = $1
+		SELECT value FROM counters WHERE name = $1 AND labels = '{}'::jsonb
 	`, name).Scan(&value)
 
 	if err != nil {
@@ -139,54 +323,310 @@ func (r *PostgresRepository) GetCounter(ctx context.Context, name string) (int64
 	return value, nil
 }
 
+func (r *PostgresRepository) UpdateHistogram(ctx context.Context, name string, value models.Histogram) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := mergeHistogram(ctx, tx, name, nil, value); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetHistogram(ctx context.Context, name string) (models.Histogram, error) {
+	var data []byte
+	err := r.db.QueryRowContext(ctx, `
+		SELECT data FROM histograms WHERE name = $1 AND labels = '{}'::jsonb
+	`, name).Scan(&data)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Histogram{}, errors.New("histogram not found")
+		}
+		return models.Histogram{}, fmt.Errorf("failed to get histogram: %w", err)
+	}
+
+	var value models.Histogram
+	if err := json.Unmarshal(data, &value); err != nil {
+		return models.Histogram{}, fmt.Errorf("failed to decode histogram: %w", err)
+	}
+	return value, nil
+}
+
+func (r *PostgresRepository) UpdateSummary(ctx context.Context, name string, value models.Summary) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := mergeSummary(ctx, tx, name, nil, value); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetSummary(ctx context.Context, name string) (models.Summary, error) {
+	var data []byte
+	err := r.db.QueryRowContext(ctx, `
+		SELECT data FROM summaries WHERE name = $1 AND labels = '{}'::jsonb
+	`, name).Scan(&data)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Summary{}, errors.New("summary not found")
+		}
+		return models.Summary{}, fmt.Errorf("failed to get summary: %w", err)
+	}
+
+	var value models.Summary
+	if err := json.Unmarshal(data, &value); err != nil {
+		return models.Summary{}, fmt.Errorf("failed to decode summary: %w", err)
+	}
+	return value, nil
+}
+
+// mergeHistogram добавляет значения гистограммы к сохраненной в рамках транзакции.
+// Сначала пытаемся вставить новую запись, а при конфликте блокируем существующую
+// строку и объединяем значения, чтобы параллельные обновления не терялись.
+func mergeHistogram(ctx context.Context, tx *sql.Tx, name string, labels map[string]string, value models.Histogram) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode histogram %s: %w", name, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO histograms (name, labels, data)
+		VALUES ($1, $2::jsonb, $3)
+		ON CONFLICT (name, labels) DO NOTHING
+	`, name, labelsJSON(labels), data)
+	if err != nil {
+		return fmt.Errorf("failed to insert histogram %s: %w", name, err)
+	}
+	if inserted, err := res.RowsAffected(); err == nil && inserted > 0 {
+		return nil
+	}
+
+	var stored []byte
+	if err := tx.QueryRowContext(ctx, `
+		SELECT data FROM histograms WHERE name = $1 AND labels = $2::jsonb FOR UPDATE
+	`, name, labelsJSON(labels)).Scan(&stored); err != nil {
+		return fmt.Errorf("failed to lock histogram %s: %w", name, err)
+	}
+
+	var current models.Histogram
+	if err := json.Unmarshal(stored, &current); err != nil {
+		return fmt.Errorf("failed to decode histogram %s: %w", name, err)
+	}
+	if err := current.Merge(value); err != nil {
+		return fmt.Errorf("failed to merge histogram %s: %w", name, err)
+	}
+
+	data, err = json.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("failed to encode histogram %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE histograms SET data = $3 WHERE name = $1 AND labels = $2::jsonb
+	`, name, labelsJSON(labels), data); err != nil {
+		return fmt.Errorf("failed to update histogram %s: %w", name, err)
+	}
+	return nil
+}
+
+// mergeSummary применяет значения сводки к сохраненной в рамках транзакции.
+func mergeSummary(ctx context.Context, tx *sql.Tx, name string, labels map[string]string, value models.Summary) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary %s: %w", name, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO summaries (name, labels, data)
+		VALUES ($1, $2::jsonb, $3)
+		ON CONFLICT (name, labels) DO NOTHING
+	`, name, labelsJSON(labels), data)
+	if err != nil {
+		return fmt.Errorf("failed to insert summary %s: %w", name, err)
+	}
+	if inserted, err := res.RowsAffected(); err == nil && inserted > 0 {
+		return nil
+	}
+
+	var stored []byte
+	if err := tx.QueryRowContext(ctx, `
+		SELECT data FROM summaries WHERE name = $1 AND labels = $2::jsonb FOR UPDATE
+	`, name, labelsJSON(labels)).Scan(&stored); err != nil {
+		return fmt.Errorf("failed to lock summary %s: %w", name, err)
+	}
+
+	var current models.Summary
+	if err := json.Unmarshal(stored, &current); err != nil {
+		return fmt.Errorf("failed to decode summary %s: %w", name, err)
+	}
+	current.Merge(value)
+
+	data, err = json.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE summaries SET data = $3 WHERE name = $1 AND labels = $2::jsonb
+	`, name, labelsJSON(labels), data); err != nil {
+		return fmt.Errorf("failed to update summary %s: %w", name, err)
+	}
+	return nil
+}
+
 func (r *PostgresRepository) GetAllMetrics(ctx context.Context) (map[string]interface{}, error) {
-	metrics := make(map[string]interface{})
+	metrics, err := r.ListMetrics(ctx)
+	return metricsToMap(metrics), err
+}
+
+func (r *PostgresRepository) ListMetrics(ctx context.Context) ([]models.Metrics, error) {
+	var metrics []models.Metrics
 	var errs []error
 
-	// Получаем gauge метрики
-	rows, err := r.db.QueryContext(ctx, `SELECT name, value FROM gauges`)
+	for _, mType := range metricTypes {
+		series, err := r.querySeries(ctx, mType, "")
+		if err != nil {
+			errs = append(errs, err)
+		}
+		metrics = append(metrics, series...)
+	}
+
+	if len(errs) > 0 {
+		return metrics, fmt.Errorf("partial metrics retrieved with errors: %v", errors.Join(errs...))
+	}
+	return metrics, nil
+}
+
+func (r *PostgresRepository) FindMetrics(ctx context.Context, mType, name string, filter map[string]string) ([]models.Metrics, error) {
+	return r.querySeries(ctx, mType, "WHERE name = $1 AND labels @> $2::jsonb", name, labelsJSON(filter))
+}
+
+// querySeries читает ряды метрик типа mType, удовлетворяющие условию where.
+func (r *PostgresRepository) querySeries(ctx context.Context, mType, where string, args ...interface{}) ([]models.Metrics, error) {
+	var table, column string
+	switch mType {
+	case "gauge":
+		table, column = "gauges", "value"
+	case "counter":
+		table, column = "counters", "value"
+	case "histogram":
+		table, column = "histograms", "data"
+	case "summary":
+		table, column = "summaries", "data"
+	default:
+		return nil, fmt.Errorf("unknown metric type: %s", mType)
+	}
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT name, labels, %s FROM %s %s ORDER BY name, labels`, column, table, where), args...)
 	if err != nil {
-		errs = append(errs, fmt.Errorf("failed to get gauges: %w", err))
-	} else {
-		defer rows.Close()
-		for rows.Next() {
-			var name string
+		return nil, fmt.Errorf("failed to get %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var metrics []models.Metrics
+	var errs []error
+	for rows.Next() {
+		var labels []byte
+		metric := models.Metrics{MType: mType}
+
+		switch mType {
+		case "gauge":
 			var value float64
-			if err := rows.Scan(&name, &value); err != nil {
-				errs = append(errs, fmt.Errorf("failed to scan gauge row: %w", err))
-				continue
+			err = rows.Scan(&metric.ID, &labels, &value)
+			metric.Value = &value
+		case "counter":
+			var value int64
+			err = rows.Scan(&metric.ID, &labels, &value)
+			metric.Delta = &value
+		case "histogram":
+			var data []byte
+			if err = rows.Scan(&metric.ID, &labels, &data); err == nil {
+				metric.Histogram = &models.Histogram{}
+				err = json.Unmarshal(data, metric.Histogram)
 			}
-			metrics[name] = value
+		case "summary":
+			var data []byte
+			if err = rows.Scan(&metric.ID, &labels, &data); err == nil {
+				metric.Summary = &models.Summary{}
+				err = json.Unmarshal(data, metric.Summary)
+			}
+		}
+		if err == nil {
+			metric.Labels, err = decodeLabels(labels)
 		}
-		if err := rows.Err(); err != nil {
-			errs = append(errs, fmt.Errorf("error after iterating gauge rows: %w", err))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to scan %s row: %w", mType, err))
+			continue
 		}
+		metrics = append(metrics, metric)
+	}
+	if err := rows.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("error after iterating %s rows: %w", mType, err))
 	}
 
-	// Получаем counter метрики
-	rows, err = r.db.QueryContext(ctx, `SELECT name, value FROM counters`)
-	if err != nil {
-		errs = append(errs, fmt.Errorf("failed to get counters: %w", err))
+	return metrics, errors.Join(errs...)
+}
+
+func (r *PostgresRepository) GetHistory(ctx context.Context, mType, name string, labels map[string]string, from, to time.Time, step time.Duration) ([]models.HistoryPoint, error) {
+	var rows *sql.Rows
+	var err error
+	if step <= 0 {
+		rows, err = r.db.QueryContext(ctx, `
+			SELECT ts, value, value
+			FROM metric_history
+			WHERE mtype = $1 AND name = $2 AND labels = $5::jsonb AND ts BETWEEN $3 AND $4
+			ORDER BY ts
+		`, mType, name, from, to, labelsJSON(labels))
 	} else {
-		defer rows.Close()
-		for rows.Next() {
-			var name string
-			var value int64
-			if err := rows.Scan(&name, &value); err != nil {
-				errs = append(errs, fmt.Errorf("failed to scan counter row: %w", err))
-				continue
-			}
-			metrics[name] = value
+		// Интервалы отсчитываются от from; для каждого берем среднее и последнее значение
+		rows, err = r.db.QueryContext(ctx, `
+			SELECT $3::timestamptz + floor(extract(epoch FROM ts - $3::timestamptz)::float8 / $5::float8) * $5::float8 * interval '1 second' AS bucket,
+				avg(value),
+				(array_agg(value ORDER BY ts DESC))[1]
+			FROM metric_history
+			WHERE mtype = $1 AND name = $2 AND labels = $6::jsonb AND ts BETWEEN $3 AND $4
+			GROUP BY bucket
+			ORDER BY bucket
+		`, mType, name, from, to, step.Seconds(), labelsJSON(labels))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+	defer rows.Close()
+
+	points := make([]models.HistoryPoint, 0)
+	for rows.Next() {
+		var point models.HistoryPoint
+		var avg, last float64
+		if err := rows.Scan(&point.Timestamp, &avg, &last); err != nil {
+			return nil, fmt.Errorf("failed to scan history row: %w", err)
 		}
-		if err := rows.Err(); err != nil {
-			errs = append(errs, fmt.Errorf("error after iterating counter rows: %w", err))
+		point.Value = avg
+		if mType == "counter" {
+			point.Value = last
 		}
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating history rows: %w", err)
 	}
+	return points, nil
+}
 
-	if len(errs) > 0 {
-		return metrics, fmt.Errorf("partial metrics retrieved with errors: %v", errors.Join(errs...))
+func (r *PostgresRepository) PruneHistory(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM metric_history WHERE ts < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune history: %w", err)
 	}
-	return metrics, nil
+	return result.RowsAffected()
 }
 
 func (r *PostgresRepository) UpdateMetrics(ctx context.Context, metrics []models.Metrics) error {
@@ -196,38 +636,107 @@ func (r *PostgresRepository) UpdateMetrics(ctx context.Context, metrics []models
 	}
 	defer tx.Rollback()
 
+	if err := applyMetrics(ctx, tx, metrics); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) UpdateMetricsIdempotent(ctx context.Context, key string, metrics []models.Metrics, response []byte) ([]byte, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Конкурентный запрос с тем же ключом ждет на уникальном индексе,
+	// пока первая транзакция не завершится
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, response) VALUES ($1, $2)
+		ON CONFLICT (key) DO NOTHING
+	`, key, response)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save idempotency key: %w", err)
+	}
+
+	if inserted, err := res.RowsAffected(); err == nil && inserted == 0 {
+		var stored []byte
+		var expired bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT response, created_at < now() - make_interval(secs => $2)
+			FROM idempotency_keys WHERE key = $1
+		`, key, idempotencyTTL.Seconds()).Scan(&stored, &expired); err != nil {
+			return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		if !expired {
+			return stored, true, nil
+		}
+
+		// Запись устарела — применяем пакет как новый
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE idempotency_keys SET response = $2, created_at = now() WHERE key = $1
+		`, key, response); err != nil {
+			return nil, false, fmt.Errorf("failed to update idempotency key: %w", err)
+		}
+	}
+
+	if err := applyMetrics(ctx, tx, metrics); err != nil {
+		return nil, false, err
+	}
+
+	// Удаляем устаревшие ключи и ключи сверх лимита
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE created_at < now() - make_interval(secs => $1)
+		   OR key IN (SELECT key FROM idempotency_keys ORDER BY created_at DESC OFFSET $2)
+	`, idempotencyTTL.Seconds(), idempotencyCapacity); err != nil {
+		return nil, false, fmt.Errorf("failed to clean up idempotency keys: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return response, false, nil
+}
+
+// applyMetrics применяет пакет метрик в рамках транзакции tx.
+func applyMetrics(ctx context.Context, tx *sql.Tx, metrics []models.Metrics) error {
 	for _, metric := range metrics {
 		switch metric.MType {
 		case "gauge":
 			if metric.Value == nil {
 				continue
 			}
-			_, err := tx.ExecContext(ctx, `
-				INSERT INTO gauges (name, value)
-				VALUES ($1, $2)
-				ON CONFLICT (name)
-				DO UPDATE SET value = EXCLUDED.value
-			`, metric.ID, *metric.Value)
-			if err != nil {
-				return fmt.Errorf("failed to update gauge %s: %w", metric.ID, err)
+			if err := upsertGauge(ctx, tx, metric.ID, metric.Labels, *metric.Value); err != nil {
+				return err
 			}
 
 		case "counter":
 			if metric.Delta == nil {
 				continue
 			}
-			_, err := tx.ExecContext(ctx, `
-				INSERT INTO counters (name, value)
-				VALUES ($1, $2)
-				ON CONFLICT (name)
-				DO UPDATE SET value = counters.value + EXCLUDED.value
-			`, metric.ID, *metric.Delta)
-			if err != nil {
-				return fmt.Errorf("failed to update counter %s: %w", metric.ID, err)
+			if err := upsertCounter(ctx, tx, metric.ID, metric.Labels, *metric.Delta); err != nil {
+				return err
+			}
+
+		case "histogram":
+			if metric.Histogram == nil {
+				continue
+			}
+			if err := mergeHistogram(ctx, tx, metric.ID, metric.Labels, *metric.Histogram); err != nil {
+				return err
+			}
+
+		case "summary":
+			if metric.Summary == nil {
+				continue
+			}
+			if err := mergeSummary(ctx, tx, metric.ID, metric.Labels, *metric.Summary); err != nil {
+				return err
 			}
 		}
 	}
-	return tx.Commit()
+	return nil
 }
 
 func (r *PostgresRepository) SaveToFile(ctx context.Context, filename string) error {
@@ -238,37 +747,127 @@ func (r *PostgresRepository) LoadFromFile(ctx context.Context, filename string)
 	return nil
 }
 
+// labelsJSON возвращает набор меток в виде JSON для колонки labels.
+func labelsJSON(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "{}"
+	}
+	return models.LabelsKey(labels)
+}
+
+func decodeLabels(data []byte) (map[string]string, error) {
+	var labels map[string]string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return labels, nil
+}
+
+// seriesKey идентифицирует ряд метрики по имени и набору меток.
+type seriesKey struct {
+	name   string
+	labels string
+}
+
+func newSeriesKey(name string, labels map[string]string) seriesKey {
+	return seriesKey{name: name, labels: models.LabelsKey(labels)}
+}
+
+func (k seriesKey) labelSet() map[string]string {
+	labels, _ := models.ParseLabelsKey(k.labels)
+	return labels
+}
+
 type MemoryRepository struct {
-	gauges   map[string]float64
-	counters map[string]int64
-	mu       sync.Mutex
+	gauges     map[seriesKey]float64
+	counters   map[seriesKey]int64
+	histograms map[seriesKey]models.Histogram
+	summaries  map[seriesKey]models.Summary
+	history    map[historyKey]*historyRing
+	applied    *idempotencyStore
+	now        func() time.Time
+	mu         sync.Mutex
 }
 
 func NewMemoryRepository() *MemoryRepository {
 	return &MemoryRepository{
-		gauges:   make(map[string]float64),
-		counters: make(map[string]int64),
+		gauges:     make(map[seriesKey]float64),
+		counters:   make(map[seriesKey]int64),
+		histograms: make(map[seriesKey]models.Histogram),
+		summaries:  make(map[seriesKey]models.Summary),
+		history:    make(map[historyKey]*historyRing),
+		applied:    newIdempotencyStore(),
+		now:        time.Now,
 	}
 }
 
 func (r *MemoryRepository) UpdateGauge(ctx context.Context, name string, value float64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.gauges[name] = value
+	r.setGauge(seriesKey{name: name}, value)
 	return nil
 }
 
 func (r *MemoryRepository) UpdateCounter(ctx context.Context, name string, value int64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.counters[name] += value
+	r.addCounter(seriesKey{name: name}, value)
 	return nil
 }
 
+// setGauge сохраняет значение gauge и добавляет его в историю. Вызывается под r.mu.
+func (r *MemoryRepository) setGauge(key seriesKey, value float64) {
+	r.gauges[key] = value
+	r.appendHistory("gauge", key, value)
+}
+
+// addCounter увеличивает counter и добавляет в историю накопленное значение. Вызывается под r.mu.
+func (r *MemoryRepository) addCounter(key seriesKey, delta int64) {
+	r.counters[key] += delta
+	r.appendHistory("counter", key, float64(r.counters[key]))
+}
+
+func (r *MemoryRepository) appendHistory(mType string, key seriesKey, value float64) {
+	hk := historyKey{mType: mType, series: key}
+	ring, ok := r.history[hk]
+	if !ok {
+		ring = newHistoryRing(historyCapacity)
+		r.history[hk] = ring
+	}
+	ring.add(models.HistoryPoint{Timestamp: r.now(), Value: value})
+}
+
+func (r *MemoryRepository) GetHistory(ctx context.Context, mType, name string, labels map[string]string, from, to time.Time, step time.Duration) ([]models.HistoryPoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ring, ok := r.history[historyKey{mType: mType, series: newSeriesKey(name, labels)}]
+	if !ok {
+		return []models.HistoryPoint{}, nil
+	}
+	return downsample(ring.rangeOf(from, to), mType, from, step), nil
+}
+
+func (r *MemoryRepository) PruneHistory(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pruned int64
+	for key, ring := range r.history {
+		pruned += int64(ring.prune(before))
+		if len(ring.points) == 0 {
+			delete(r.history, key)
+		}
+	}
+	return pruned, nil
+}
+
 func (r *MemoryRepository) GetGauge(ctx context.Context, name string) (float64, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if value, ok := r.gauges[name]; ok {
+	if value, ok := r.gauges[seriesKey{name: name}]; ok {
 		return value, nil
 	}
 	return 0, errors.New("gauge not found")
@@ -277,43 +876,233 @@ func (r *MemoryRepository) GetGauge(ctx context.Context, name string) (float64,
 func (r *MemoryRepository) GetCounter(ctx context.Context, name string) (int64, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if value, ok := r.counters[name]; ok {
+	if value, ok := r.counters[seriesKey{name: name}]; ok {
 		return value, nil
 	}
 	return 0, errors.New("counter not found")
 }
 
-func (r *MemoryRepository) GetAllMetrics(ctx context.Context) (map[string]interface{}, error) {
+func (r *MemoryRepository) UpdateHistogram(ctx context.Context, name string, value models.Histogram) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mergeHistogram(seriesKey{name: name}, value)
+}
+
+func (r *MemoryRepository) GetHistogram(ctx context.Context, name string) (models.Histogram, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if value, ok := r.histograms[seriesKey{name: name}]; ok {
+		return value.Clone(), nil
+	}
+	return models.Histogram{}, errors.New("histogram not found")
+}
+
+func (r *MemoryRepository) UpdateSummary(ctx context.Context, name string, value models.Summary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mergeSummary(seriesKey{name: name}, value)
+	return nil
+}
+
+func (r *MemoryRepository) GetSummary(ctx context.Context, name string) (models.Summary, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	metrics := make(map[string]interface{})
-	for name, value := range r.gauges {
-		metrics[name] = value
+	if value, ok := r.summaries[seriesKey{name: name}]; ok {
+		return value.Clone(), nil
+	}
+	return models.Summary{}, errors.New("summary not found")
+}
+
+// mergeHistogram объединяет гистограмму с сохраненной. Вызывается под r.mu.
+func (r *MemoryRepository) mergeHistogram(key seriesKey, value models.Histogram) error {
+	current, ok := r.histograms[key]
+	if !ok {
+		r.histograms[key] = value.Clone()
+		return nil
+	}
+	if err := current.Merge(value); err != nil {
+		return fmt.Errorf("failed to merge histogram %s: %w", key.name, err)
 	}
-	for name, value := range r.counters {
-		metrics[name] = value
+	r.histograms[key] = current
+	return nil
+}
+
+// mergeSummary применяет сводку к сохраненной. Вызывается под r.mu.
+func (r *MemoryRepository) mergeSummary(key seriesKey, value models.Summary) {
+	current, ok := r.summaries[key]
+	if !ok {
+		r.summaries[key] = value.Clone()
+		return
+	}
+	current.Merge(value)
+	r.summaries[key] = current
+}
+
+func (r *MemoryRepository) GetAllMetrics(ctx context.Context) (map[string]interface{}, error) {
+	metrics, err := r.ListMetrics(ctx)
+	return metricsToMap(metrics), err
+}
+
+func (r *MemoryRepository) ListMetrics(ctx context.Context) ([]models.Metrics, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var metrics []models.Metrics
+	for _, mType := range metricTypes {
+		metrics = append(metrics, r.collect(mType, func(key seriesKey) bool { return true })...)
 	}
 	return metrics, nil
 }
 
+func (r *MemoryRepository) FindMetrics(ctx context.Context, mType, name string, filter map[string]string) ([]models.Metrics, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch mType {
+	case "gauge", "counter", "histogram", "summary":
+	default:
+		return nil, fmt.Errorf("unknown metric type: %s", mType)
+	}
+	return r.collect(mType, func(key seriesKey) bool {
+		return key.name == name && models.MatchLabels(key.labelSet(), filter)
+	}), nil
+}
+
+// collect возвращает ряды метрик типа mType, для которых match возвращает true,
+// упорядоченные по имени и меткам. Вызывается под r.mu.
+func (r *MemoryRepository) collect(mType string, match func(seriesKey) bool) []models.Metrics {
+	var metrics []models.Metrics
+	add := func(key seriesKey, metric models.Metrics) {
+		metric.ID = key.name
+		metric.MType = mType
+		metric.Labels = key.labelSet()
+		metrics = append(metrics, metric)
+	}
+
+	switch mType {
+	case "gauge":
+		for key, value := range r.gauges {
+			if match(key) {
+				v := value
+				add(key, models.Metrics{Value: &v})
+			}
+		}
+	case "counter":
+		for key, value := range r.counters {
+			if match(key) {
+				v := value
+				add(key, models.Metrics{Delta: &v})
+			}
+		}
+	case "histogram":
+		for key, value := range r.histograms {
+			if match(key) {
+				v := value.Clone()
+				add(key, models.Metrics{Histogram: &v})
+			}
+		}
+	case "summary":
+		for key, value := range r.summaries {
+			if match(key) {
+				v := value.Clone()
+				add(key, models.Metrics{Summary: &v})
+			}
+		}
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].ID != metrics[j].ID {
+			return metrics[i].ID < metrics[j].ID
+		}
+		return models.LabelsKey(metrics[i].Labels) < models.LabelsKey(metrics[j].Labels)
+	})
+	return metrics
+}
+
 func (r *MemoryRepository) UpdateMetrics(ctx context.Context, metrics []models.Metrics) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.updateMetrics(metrics)
+}
+
+func (r *MemoryRepository) UpdateMetricsIdempotent(ctx context.Context, key string, metrics []models.Metrics, response []byte) ([]byte, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if stored, ok := r.applied.get(key, now); ok {
+		return stored, true, nil
+	}
+	if err := r.updateMetrics(metrics); err != nil {
+		return nil, false, err
+	}
+	r.applied.put(key, response, now)
+	return response, false, nil
+}
+
+// updateMetrics применяет пакет метрик целиком. Вызывается под r.mu.
+func (r *MemoryRepository) updateMetrics(metrics []models.Metrics) error {
+	// Проверяем совместимость гистограмм заранее, чтобы пакет применялся целиком:
+	// границы сверяются и с сохраненными значениями, и с предыдущими
+	// гистограммами того же ряда в пакете
+	pending := make(map[seriesKey]models.Histogram)
+	for _, metric := range metrics {
+		if metric.MType != "histogram" || metric.Histogram == nil {
+			continue
+		}
+		key := newSeriesKey(metric.ID, metric.Labels)
+		check, ok := pending[key]
+		if !ok {
+			current, stored := r.histograms[key]
+			if !stored {
+				pending[key] = metric.Histogram.Clone()
+				continue
+			}
+			check = current.Clone()
+		}
+		if err := check.Merge(*metric.Histogram); err != nil {
+			return fmt.Errorf("failed to merge histogram %s: %w", metric.ID, err)
+		}
+		pending[key] = check
+	}
+
 	for _, metric := range metrics {
+		key := newSeriesKey(metric.ID, metric.Labels)
 		switch metric.MType {
 		case "gauge":
 			if metric.Value != nil {
-				r.gauges[metric.ID] = *metric.Value
+				r.setGauge(key, *metric.Value)
 			}
 		case "counter":
 			if metric.Delta != nil {
-				r.counters[metric.ID] += *metric.Delta
+				r.addCounter(key, *metric.Delta)
+			}
+		case "histogram":
+			if metric.Histogram != nil {
+				if err := r.mergeHistogram(key, *metric.Histogram); err != nil {
+					return err
+				}
+			}
+		case "summary":
+			if metric.Summary != nil {
+				r.mergeSummary(key, *metric.Summary)
 			}
 		}
 	}
 	return nil
 }
 
+// fileData — формат файла с сохраненными метриками.
+// Ряды без меток хранятся в словарях по имени для совместимости со старыми файлами,
+// ряды с метками — списком в Labeled.
+type fileData struct {
+	Gauges     map[string]float64          `json:"gauges"`
+	Counters   map[string]int64            `json:"counters"`
+	Histograms map[string]models.Histogram `json:"histograms"`
+	Summaries  map[string]models.Summary   `json:"summaries"`
+	Labeled    []models.Metrics            `json:"labeled,omitempty"`
+}
+
 func (r *MemoryRepository) SaveToFile(ctx context.Context, filename string) error {
 	if filename == "" {
 		return nil
@@ -322,12 +1111,29 @@ func (r *MemoryRepository) SaveToFile(ctx context.Context, filename string) erro
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	data := struct {
-		Gauges   map[string]float64 `json:"gauges"`
-		Counters map[string]int64   `json:"counters"`
-	}{
-		Gauges:   r.gauges,
-		Counters: r.counters,
+	data := fileData{
+		Gauges:     make(map[string]float64),
+		Counters:   make(map[string]int64),
+		Histograms: make(map[string]models.Histogram),
+		Summaries:  make(map[string]models.Summary),
+	}
+	for _, mType := range metricTypes {
+		for _, metric := range r.collect(mType, func(key seriesKey) bool { return true }) {
+			if len(metric.Labels) > 0 {
+				data.Labeled = append(data.Labeled, metric)
+				continue
+			}
+			switch mType {
+			case "gauge":
+				data.Gauges[metric.ID] = *metric.Value
+			case "counter":
+				data.Counters[metric.ID] = *metric.Delta
+			case "histogram":
+				data.Histograms[metric.ID] = *metric.Histogram
+			case "summary":
+				data.Summaries[metric.ID] = *metric.Summary
+			}
+		}
 	}
 
 	file, err := os.Create(filename)
@@ -358,20 +1164,63 @@ func (r *MemoryRepository) LoadFromFile(ctx context.Context, filename string) er
 	}
 	defer file.Close()
 
-	var data struct {
-		Gauges   map[string]float64 `json:"gauges"`
-		Counters map[string]int64   `json:"counters"`
-	}
-
+	var data fileData
 	if err := json.NewDecoder(file).Decode(&data); err != nil {
 		return err
 	}
 
-	r.gauges = data.Gauges
-	r.counters = data.Counters
+	r.gauges = make(map[seriesKey]float64)
+	r.counters = make(map[seriesKey]int64)
+	r.histograms = make(map[seriesKey]models.Histogram)
+	r.summaries = make(map[seriesKey]models.Summary)
+
+	for name, value := range data.Gauges {
+		r.gauges[seriesKey{name: name}] = value
+	}
+	for name, value := range data.Counters {
+		r.counters[seriesKey{name: name}] = value
+	}
+	for name, value := range data.Histograms {
+		r.histograms[seriesKey{name: name}] = value
+	}
+	for name, value := range data.Summaries {
+		r.summaries[seriesKey{name: name}] = value
+	}
+	for _, metric := range data.Labeled {
+		key := newSeriesKey(metric.ID, metric.Labels)
+		switch {
+		case metric.MType == "gauge" && metric.Value != nil:
+			r.gauges[key] = *metric.Value
+		case metric.MType == "counter" && metric.Delta != nil:
+			r.counters[key] = *metric.Delta
+		case metric.MType == "histogram" && metric.Histogram != nil:
+			r.histograms[key] = *metric.Histogram
+		case metric.MType == "summary" && metric.Summary != nil:
+			r.summaries[key] = *metric.Summary
+		}
+	}
 	return nil
 }
 
+// metricsToMap преобразует список метрик в словарь значений по имени ряда.
+func metricsToMap(metrics []models.Metrics) map[string]interface{} {
+	result := make(map[string]interface{}, len(metrics))
+	for _, metric := range metrics {
+		name := models.SeriesName(metric.ID, metric.Labels)
+		switch {
+		case metric.Value != nil:
+			result[name] = *metric.Value
+		case metric.Delta != nil:
+			result[name] = *metric.Delta
+		case metric.Histogram != nil:
+			result[name] = *metric.Histogram
+		case metric.Summary != nil:
+			result[name] = *metric.Summary
+		}
+	}
+	return result
+}
+
 func isRetryableDBError(err error) bool {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
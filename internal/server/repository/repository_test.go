@@ -4,7 +4,11 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
+
+	"go-metrics-server/internal/models"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -64,3 +68,262 @@ func TestMemoryRepository(t *testing.T) {
 	err = repo.SaveToFile(ctx, "")
 	assert.NoError(t, err) // Должно просто игнорироваться
 }
+
+func TestMemoryRepository_HistogramAndSummary(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	h := models.NewHistogram([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+
+	// Тест 1: Гистограммы суммируются
+	err := repo.UpdateMetrics(ctx, []models.Metrics{{ID: "latency", MType: "histogram", Histogram: h}})
+	assert.NoError(t, err)
+	err = repo.UpdateHistogram(ctx, "latency", *h)
+	assert.NoError(t, err)
+
+	stored, err := repo.GetHistogram(ctx, "latency")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2, 2, 0}, stored.Counts)
+	assert.Equal(t, uint64(4), stored.Count)
+
+	// Тест 2: Несовпадающие границы отклоняются без частичного применения пакета
+	other := models.NewHistogram([]float64{5})
+	delta := int64(1)
+	err = repo.UpdateMetrics(ctx, []models.Metrics{
+		{ID: "requests", MType: "counter", Delta: &delta},
+		{ID: "latency", MType: "histogram", Histogram: other},
+	})
+	assert.ErrorIs(t, err, models.ErrBucketsMismatch)
+	_, err = repo.GetCounter(ctx, "requests")
+	assert.Error(t, err)
+
+	// Несовпадающие границы внутри пакета для нового ряда тоже отклоняют пакет целиком
+	err = repo.UpdateMetrics(ctx, []models.Metrics{
+		{ID: "size", MType: "histogram", Histogram: models.NewHistogram([]float64{1})},
+		{ID: "requests", MType: "counter", Delta: &delta},
+		{ID: "size", MType: "histogram", Histogram: other},
+	})
+	assert.ErrorIs(t, err, models.ErrBucketsMismatch)
+	_, err = repo.GetHistogram(ctx, "size")
+	assert.Error(t, err)
+	_, err = repo.GetCounter(ctx, "requests")
+	assert.Error(t, err)
+
+	// Тест 3: Сводка заменяет квантили и суммирует sum/count
+	err = repo.UpdateSummary(ctx, "rt", models.Summary{Quantiles: []models.Quantile{{Quantile: 0.9, Value: 1}}, Sum: 3, Count: 2})
+	assert.NoError(t, err)
+	err = repo.UpdateSummary(ctx, "rt", models.Summary{Quantiles: []models.Quantile{{Quantile: 0.9, Value: 2}}, Sum: 1, Count: 1})
+	assert.NoError(t, err)
+	summary, err := repo.GetSummary(ctx, "rt")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, summary.Quantiles[0].Value)
+	assert.Equal(t, uint64(3), summary.Count)
+
+	// Тест 4: Сохранение и загрузка составных метрик
+	tmpFile := filepath.Join(t.TempDir(), "metrics.json")
+	assert.NoError(t, repo.SaveToFile(ctx, tmpFile))
+
+	newRepo := NewMemoryRepository()
+	assert.NoError(t, newRepo.LoadFromFile(ctx, tmpFile))
+	loaded, err := newRepo.GetHistogram(ctx, "latency")
+	assert.NoError(t, err)
+	assert.Equal(t, stored, loaded)
+	_, err = newRepo.GetSummary(ctx, "rt")
+	assert.NoError(t, err)
+}
+
+func TestMemoryRepository_History(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	repo.now = func() time.Time { return now }
+
+	for i := 0; i < 6; i++ {
+		now = start.Add(time.Duration(i) * 10 * time.Second)
+		assert.NoError(t, repo.UpdateGauge(ctx, "HeapAlloc", float64(i)))
+		assert.NoError(t, repo.UpdateCounter(ctx, "PollCount", 1))
+	}
+
+	// Тест 1: Значения без группировки в пределах интервала
+	points, err := repo.GetHistory(ctx, "gauge", "HeapAlloc", nil, start.Add(10*time.Second), start.Add(30*time.Second), 0)
+	assert.NoError(t, err)
+	assert.Len(t, points, 3)
+	assert.Equal(t, 1.0, points[0].Value)
+
+	// Тест 2: Для gauge берется среднее по интервалу
+	points, err = repo.GetHistory(ctx, "gauge", "HeapAlloc", nil, start, start.Add(time.Minute), 30*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.HistoryPoint{
+		{Timestamp: start, Value: 1},
+		{Timestamp: start.Add(30 * time.Second), Value: 4},
+	}, points)
+
+	// Тест 3: Для counter берется последнее накопленное значение
+	points, err = repo.GetHistory(ctx, "counter", "PollCount", nil, start, start.Add(time.Minute), 30*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, points[0].Value)
+	assert.Equal(t, 6.0, points[1].Value)
+}
+
+func TestHistoryRing_Overflow(t *testing.T) {
+	ring := newHistoryRing(3)
+	start := time.Unix(0, 0)
+	assert.Empty(t, ring.rangeOf(start, start.Add(time.Hour)))
+	ring.add(models.HistoryPoint{Timestamp: start, Value: 0})
+	assert.Len(t, ring.points, 1)
+	assert.Equal(t, 1, cap(ring.points))
+
+	for i := 1; i < 5; i++ {
+		ring.add(models.HistoryPoint{Timestamp: start.Add(time.Duration(i) * time.Second), Value: float64(i)})
+	}
+
+	points := ring.rangeOf(start, start.Add(time.Hour))
+	assert.Len(t, points, 3)
+	assert.Equal(t, 2.0, points[0].Value)
+	assert.Equal(t, 4.0, points[2].Value)
+}
+
+func TestMemoryRepository_PruneHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	repo.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		now = start.Add(time.Duration(i) * time.Hour)
+		assert.NoError(t, repo.UpdateGauge(ctx, "HeapAlloc", float64(i)))
+	}
+	now = start
+	assert.NoError(t, repo.UpdateCounter(ctx, "PollCount", 1))
+
+	// Удаляются значения раньше границы, ряд без значений забывается
+	pruned, err := repo.PruneHistory(ctx, start.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), pruned)
+	assert.Len(t, repo.history, 1)
+
+	points, err := repo.GetHistory(ctx, "gauge", "HeapAlloc", nil, start, start.Add(time.Hour*24), 0)
+	assert.NoError(t, err)
+	assert.Len(t, points, 2)
+	assert.Equal(t, 2.0, points[0].Value)
+
+	// После удаления новые значения добавляются в конец
+	now = start.Add(4 * time.Hour)
+	assert.NoError(t, repo.UpdateGauge(ctx, "HeapAlloc", 4))
+	points, err = repo.GetHistory(ctx, "gauge", "HeapAlloc", nil, start, start.Add(time.Hour*24), 0)
+	assert.NoError(t, err)
+	assert.Len(t, points, 3)
+	assert.Equal(t, 4.0, points[2].Value)
+}
+
+func TestMemoryRepository_Labels(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	v1, v2 := 10.0, 30.0
+	d := int64(5)
+	err := repo.UpdateMetrics(ctx, []models.Metrics{
+		{ID: "cpu", MType: "gauge", Value: &v1, Labels: map[string]string{"host": "a", "core": "1"}},
+		{ID: "cpu", MType: "gauge", Value: &v2, Labels: map[string]string{"host": "b", "core": "1"}},
+		{ID: "requests", MType: "counter", Delta: &d, Labels: map[string]string{"host": "a"}},
+		{ID: "requests", MType: "counter", Delta: &d, Labels: map[string]string{"host": "a"}},
+	})
+	assert.NoError(t, err)
+
+	// Тест 1: Ряды с разными метками хранятся отдельно
+	series, err := repo.FindMetrics(ctx, "gauge", "cpu", nil)
+	assert.NoError(t, err)
+	assert.Len(t, series, 2)
+
+	series, err = repo.FindMetrics(ctx, "gauge", "cpu", map[string]string{"host": "b"})
+	assert.NoError(t, err)
+	if assert.Len(t, series, 1) {
+		assert.Equal(t, 30.0, *series[0].Value)
+		assert.Equal(t, map[string]string{"host": "b", "core": "1"}, series[0].Labels)
+	}
+
+	series, err = repo.FindMetrics(ctx, "counter", "requests", map[string]string{"host": "a"})
+	assert.NoError(t, err)
+	if assert.Len(t, series, 1) {
+		assert.Equal(t, int64(10), *series[0].Delta)
+	}
+
+	// Тест 2: Ряд без меток не смешивается с рядами с метками
+	_, err = repo.GetGauge(ctx, "cpu")
+	assert.Error(t, err)
+
+	// Тест 3: Метки сохраняются в файл и загружаются обратно
+	assert.NoError(t, repo.UpdateGauge(ctx, "plain", 1))
+	tmpFile := filepath.Join(t.TempDir(), "metrics.json")
+	assert.NoError(t, repo.SaveToFile(ctx, tmpFile))
+
+	newRepo := NewMemoryRepository()
+	assert.NoError(t, newRepo.LoadFromFile(ctx, tmpFile))
+	all, err := newRepo.ListMetrics(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, all, 4)
+	value, err := newRepo.GetGauge(ctx, "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, value)
+
+	metrics, err := newRepo.GetAllMetrics(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, metrics, `cpu{core="1",host="a"}`)
+}
+
+func TestMemoryRepository_Idempotency(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	repo.now = func() time.Time { return now }
+
+	delta := int64(5)
+	batch := []models.Metrics{{ID: "PollCount", MType: "counter", Delta: &delta}}
+
+	stored, duplicate, err := repo.UpdateMetricsIdempotent(ctx, "key", batch, []byte("first"))
+	assert.NoError(t, err)
+	assert.False(t, duplicate)
+	assert.Equal(t, []byte("first"), stored)
+
+	// Тест 1: Повтор не применяется, возвращается исходный ответ
+	stored, duplicate, err = repo.UpdateMetricsIdempotent(ctx, "key", batch, []byte("second"))
+	assert.NoError(t, err)
+	assert.True(t, duplicate)
+	assert.Equal(t, []byte("first"), stored)
+
+	value, err := repo.GetCounter(ctx, "PollCount")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), value)
+
+	// Тест 2: После истечения срока ключ применяется заново
+	now = start.Add(idempotencyTTL + time.Second)
+	_, duplicate, err = repo.UpdateMetricsIdempotent(ctx, "key", batch, []byte("third"))
+	assert.NoError(t, err)
+	assert.False(t, duplicate)
+
+	value, err = repo.GetCounter(ctx, "PollCount")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), value)
+}
+
+func TestIdempotencyStore_Capacity(t *testing.T) {
+	store := newIdempotencyStore()
+	now := time.Unix(0, 0)
+	for i := 0; i <= idempotencyCapacity; i++ {
+		store.put(strconv.Itoa(i), nil, now)
+	}
+
+	assert.Len(t, store.records, idempotencyCapacity)
+	_, ok := store.get("0", now)
+	assert.False(t, ok)
+	_, ok = store.get(strconv.Itoa(idempotencyCapacity), now)
+	assert.True(t, ok)
+}
@@ -2,8 +2,20 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"go-metrics-server/internal/models"
 	"go-metrics-server/internal/server/repository"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrMetricNotFound возвращается, если ни один ряд не подходит под запрос.
+	ErrMetricNotFound = errors.New("metric not found")
+	// ErrInvalidAggregation возвращается для неподдерживаемой функции агрегации.
+	ErrInvalidAggregation = errors.New("invalid aggregation")
 )
 
 type MetricService struct {
@@ -30,14 +42,213 @@ func (s *MetricService) GetCounter(ctx context.Context, name string) (int64, err
 	return s.repo.GetCounter(ctx, name)
 }
 
+func (s *MetricService) UpdateHistogram(ctx context.Context, name string, value models.Histogram) error {
+	return s.repo.UpdateHistogram(ctx, name, value)
+}
+
+func (s *MetricService) GetHistogram(ctx context.Context, name string) (models.Histogram, error) {
+	return s.repo.GetHistogram(ctx, name)
+}
+
+func (s *MetricService) UpdateSummary(ctx context.Context, name string, value models.Summary) error {
+	return s.repo.UpdateSummary(ctx, name, value)
+}
+
+func (s *MetricService) GetSummary(ctx context.Context, name string) (models.Summary, error) {
+	return s.repo.GetSummary(ctx, name)
+}
+
 func (s *MetricService) GetAllMetrics(ctx context.Context) (map[string]interface{}, error) {
 	return s.repo.GetAllMetrics(ctx)
 }
 
+func (s *MetricService) ListMetrics(ctx context.Context) ([]models.Metrics, error) {
+	return s.repo.ListMetrics(ctx)
+}
+
+// ListSources возвращает источники метрик, упорядоченные по идентификатору.
+func (s *MetricService) ListSources(ctx context.Context) ([]models.Source, error) {
+	metrics, err := s.repo.ListMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, metric := range metrics {
+		if source, ok := metric.Labels[models.SourceLabel]; ok {
+			counts[source]++
+		}
+	}
+
+	sources := make([]models.Source, 0, len(counts))
+	for id, n := range counts {
+		sources = append(sources, models.Source{ID: id, Metrics: n})
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].ID < sources[j].ID })
+	return sources, nil
+}
+
+// SourceMetrics возвращает метрики, полученные от источника source.
+func (s *MetricService) SourceMetrics(ctx context.Context, source string) ([]models.Metrics, error) {
+	metrics, err := s.repo.ListMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Metrics, 0)
+	for _, metric := range metrics {
+		if metric.Labels[models.SourceLabel] == source {
+			result = append(result, metric)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrMetricNotFound
+	}
+	return result, nil
+}
+
+func (s *MetricService) FindMetrics(ctx context.Context, mType, name string, filter map[string]string) ([]models.Metrics, error) {
+	return s.repo.FindMetrics(ctx, mType, name, filter)
+}
+
+// GetMetric возвращает ряд метрики с точно совпадающим набором меток.
+func (s *MetricService) GetMetric(ctx context.Context, mType, name string, labels map[string]string) (models.Metrics, error) {
+	series, err := s.repo.FindMetrics(ctx, mType, name, labels)
+	if err != nil {
+		return models.Metrics{}, err
+	}
+	key := models.LabelsKey(labels)
+	for _, metric := range series {
+		if models.LabelsKey(metric.Labels) == key {
+			return metric, nil
+		}
+	}
+	return models.Metrics{}, ErrMetricNotFound
+}
+
+// AggregateMetrics находит ряды метрики, метки которых содержат filter, и объединяет их.
+// Если под фильтр подходит единственный ряд и функция не указана, он возвращается как есть.
+// Для gauge поддерживаются функции avg (по умолчанию), sum, min и max,
+// для counter — sum (по умолчанию), min и max. Гистограммы суммируются,
+// у сводок суммируются sum и count, а квантили отбрасываются.
+func (s *MetricService) AggregateMetrics(ctx context.Context, mType, name string, filter map[string]string, agg string) (models.Metrics, error) {
+	series, err := s.repo.FindMetrics(ctx, mType, name, filter)
+	if err != nil {
+		return models.Metrics{}, err
+	}
+	if len(series) == 0 {
+		return models.Metrics{}, ErrMetricNotFound
+	}
+	if len(series) == 1 && agg == "" {
+		return series[0], nil
+	}
+
+	result := models.Metrics{ID: name, MType: mType}
+	if len(filter) > 0 {
+		result.Labels = filter
+	}
+
+	switch mType {
+	case "gauge":
+		values := make([]float64, 0, len(series))
+		for _, metric := range series {
+			values = append(values, *metric.Value)
+		}
+		if agg == "" {
+			agg = "avg"
+		}
+		value, err := aggregateFloats(values, agg)
+		if err != nil {
+			return models.Metrics{}, err
+		}
+		result.Value = &value
+
+	case "counter":
+		if agg == "" {
+			agg = "sum"
+		}
+		if agg != "sum" && agg != "min" && agg != "max" {
+			return models.Metrics{}, fmt.Errorf("%w %q for counter", ErrInvalidAggregation, agg)
+		}
+		value := *series[0].Delta
+		for _, metric := range series[1:] {
+			d := *metric.Delta
+			switch {
+			case agg == "sum":
+				value += d
+			case agg == "min" && d < value, agg == "max" && d > value:
+				value = d
+			}
+		}
+		result.Delta = &value
+
+	case "histogram":
+		if agg != "" && agg != "sum" {
+			return models.Metrics{}, fmt.Errorf("%w %q for histogram", ErrInvalidAggregation, agg)
+		}
+		merged := series[0].Histogram.Clone()
+		for _, metric := range series[1:] {
+			if err := merged.Merge(*metric.Histogram); err != nil {
+				return models.Metrics{}, err
+			}
+		}
+		result.Histogram = &merged
+
+	case "summary":
+		if agg != "" && agg != "sum" {
+			return models.Metrics{}, fmt.Errorf("%w %q for summary", ErrInvalidAggregation, agg)
+		}
+		var merged models.Summary
+		for _, metric := range series {
+			merged.Sum += metric.Summary.Sum
+			merged.Count += metric.Summary.Count
+		}
+		result.Summary = &merged
+	}
+	return result, nil
+}
+
+func aggregateFloats(values []float64, agg string) (float64, error) {
+	switch agg {
+	case "sum", "avg":
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		if agg == "avg" {
+			return sum / float64(len(values)), nil
+		}
+		return sum, nil
+	case "min":
+		result := math.Inf(1)
+		for _, v := range values {
+			result = math.Min(result, v)
+		}
+		return result, nil
+	case "max":
+		result := math.Inf(-1)
+		for _, v := range values {
+			result = math.Max(result, v)
+		}
+		return result, nil
+	}
+	return 0, fmt.Errorf("%w %q for gauge", ErrInvalidAggregation, agg)
+}
+
+func (s *MetricService) GetHistory(ctx context.Context, mType, name string, labels map[string]string, from, to time.Time, step time.Duration) ([]models.HistoryPoint, error) {
+	return s.repo.GetHistory(ctx, mType, name, labels, from, to, step)
+}
+
 func (s *MetricService) UpdateMetrics(ctx context.Context, metrics []models.Metrics) error {
 	return s.repo.UpdateMetrics(ctx, metrics)
 }
 
+// UpdateMetricsIdempotent применяет пакет один раз для ключа key.
+// Для повторного ключа возвращается ответ, сохраненный при первом применении.
+func (s *MetricService) UpdateMetricsIdempotent(ctx context.Context, key string, metrics []models.Metrics, response []byte) ([]byte, bool, error) {
+	return s.repo.UpdateMetricsIdempotent(ctx, key, metrics, response)
+}
+
 func (s *MetricService) SaveToFile(ctx context.Context, filename string) error {
 	return s.repo.SaveToFile(ctx, filename)
 }
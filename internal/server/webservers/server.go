@@ -2,6 +2,9 @@ package webservers
 
 import (
 	"compress/gzip"
+	"crypto/rsa"
+	"fmt"
+	"go-metrics-server/internal/encryption"
 	"go-metrics-server/internal/server/config"
 	"go-metrics-server/internal/server/database"
 	handler "go-metrics-server/internal/server/handlers"
@@ -9,6 +12,7 @@ import (
 	"go-metrics-server/internal/server/repository"
 	"go-metrics-server/internal/server/service"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 
@@ -16,23 +20,52 @@ import (
 	"github.com/rs/zerolog"
 )
 
-func NewServer(cfg *config.Config, repo repository.MetricRepository, db *database.DB) *http.Server {
+func NewServer(cfg *config.Config, repo repository.MetricRepository, db *database.DB) (*http.Server, error) {
+	var privateKey *rsa.PrivateKey
+	if cfg.CryptoKey != "" {
+		key, err := encryption.LoadPrivateKey(cfg.CryptoKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load crypto key: %w", err)
+		}
+		privateKey = key
+	}
+
+	var trustedSubnet *net.IPNet
+	if cfg.TrustedSubnet != "" {
+		_, subnet, err := net.ParseCIDR(cfg.TrustedSubnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted subnet: %w", err)
+		}
+		trustedSubnet = subnet
+	}
+
 	r := chi.NewRouter()
 
 	logger := zerolog.New(zerolog.NewConsoleWriter()).With().Timestamp().Logger()
 	r.Use(middleware.LoggerMiddleware(logger))
+	r.Use(middleware.DecryptMiddleware(privateKey))
 	r.Use(gzipMiddleware)
+	r.Use(middleware.HashMiddleware(cfg.Key))
 	r.Use(jsonContentTypeMiddleware)
 
 	metricService := service.NewMetricService(repo)
 	metricHandler := handler.NewMetricHandler(metricService)
 
-	r.Post("/update/{type}/{name}/{value}", metricHandler.UpdateMetric)
+	// Запись метрик разрешена только из доверенной подсети
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.TrustedSubnetMiddleware(trustedSubnet))
+		r.Post("/update/{type}/{name}/{value}", metricHandler.UpdateMetric)
+		r.Post("/update/", metricHandler.UpdateMetricJSON)
+		r.Post("/updates/", metricHandler.BatchUpdate)
+	})
+
 	r.Get("/value/{type}/{name}", metricHandler.GetMetricValue)
 	r.Get("/", metricHandler.GetAllMetrics)
-	r.Post("/update/", metricHandler.UpdateMetricJSON)
 	r.Post("/value/", metricHandler.GetMetricValueJSON)
-	r.Post("/updates/", metricHandler.BatchUpdate)
+	r.Get("/metrics", metricHandler.GetMetricsPrometheus)
+	r.Get("/history/{type}/{name}", metricHandler.GetHistory)
+	r.Get("/sources", metricHandler.ListSources)
+	r.Get("/sources/{source}", metricHandler.GetSourceMetrics)
 
 	if db != nil {
 		pingHandler := handler.NewPingHandler(db)
@@ -42,7 +75,7 @@ func NewServer(cfg *config.Config, repo repository.MetricRepository, db *databas
 	return &http.Server{
 		Addr:    cfg.ServerAddr,
 		Handler: r,
-	}
+	}, nil
 }
 
 func gzipMiddleware(next http.Handler) http.Handler {
@@ -60,6 +93,7 @@ func gzipMiddleware(next http.Handler) http.Handler {
 			}
 			defer gz.Close()
 			r.Body = gz
+			r.Header.Del("Content-Encoding")
 		}
 
 		if supportsGzip && isCompressibleContent(r) {
@@ -99,7 +133,8 @@ func isCompressibleContent(r *http.Request) bool {
 	contentType := r.Header.Get("Content-Type")
 	return strings.Contains(contentType, "application/json") ||
 		strings.Contains(contentType, "text/html") ||
-		r.Header.Get("Accept") == "text/html"
+		r.Header.Get("Accept") == "text/html" ||
+		r.URL.Path == "/metrics"
 }
 
 type gzipResponseWriter struct {
@@ -1,21 +1,35 @@
 package webservers
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"go-metrics-server/internal/agent/sender"
 	"go-metrics-server/internal/server/config"
 	"go-metrics-server/internal/server/database"
+	"go-metrics-server/internal/server/middleware"
 	"go-metrics-server/internal/server/repository"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewServer(t *testing.T) {
 	cfg := &config.Config{ServerAddr: "localhost:8080"}
 	repo := repository.NewMemoryRepository()
-	srv := NewServer(cfg, repo, nil) // nil для DB, так как тестируем без БД
+	srv, err := NewServer(cfg, repo, nil) // nil для DB, так как тестируем без БД
+	assert.NoError(t, err)
 
 	// Тест 1: Проверка маршрута /update/
 	ts := httptest.NewServer(srv.Handler)
@@ -42,9 +56,169 @@ func TestPingHandler(t *testing.T) {
 	cfg := &config.Config{ServerAddr: "localhost:8080"}
 	repo := repository.NewMemoryRepository()
 	mockDB := &database.DB{}
-	srv := NewServer(cfg, repo, mockDB)
+	srv, err := NewServer(cfg, repo, mockDB)
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+}
+
+func TestPrometheusEndpointGzip(t *testing.T) {
+	cfg := &config.Config{ServerAddr: "localhost:8080"}
+	repo := repository.NewMemoryRepository()
+	srv, err := NewServer(cfg, repo, nil)
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/update/gauge/HeapAlloc/42.5", "text/plain", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/metrics", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	gz, err := gzip.NewReader(resp.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "HeapAlloc 42.5\n")
+}
+
+func TestHashVerification(t *testing.T) {
+	const key = "secret"
+	cfg := &config.Config{ServerAddr: "localhost:8080", Key: key}
+	srv, err := NewServer(cfg, repository.NewMemoryRepository(), nil)
+	assert.NoError(t, err)
 
 	ts := httptest.NewServer(srv.Handler)
 	defer ts.Close()
 
+	body := []byte(`[{"id":"Alloc","type":"gauge","value":1.5}]`)
+	post := func(hash string) *http.Response {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(body)
+		gz.Close()
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/updates/", &buf)
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Accept-Encoding", "gzip")
+		if hash != "" {
+			req.Header.Set("HashSHA256", hash)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("signed request gets signed gzip response", func(t *testing.T) {
+		resp := post(middleware.Sign(key, body))
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+		gz, err := gzip.NewReader(resp.Body)
+		assert.NoError(t, err)
+		data, err := io.ReadAll(gz)
+		assert.NoError(t, err)
+		assert.True(t, middleware.ValidHash(key, data, resp.Header.Get("HashSHA256")))
+	})
+
+	t.Run("unsigned request", func(t *testing.T) {
+		resp := post("")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("tampered request", func(t *testing.T) {
+		resp := post(middleware.Sign("other", body))
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestEncryptedRequests(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "private.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	cfg := &config.Config{ServerAddr: "localhost:8080", Key: "secret", CryptoKey: keyPath}
+	repo := repository.NewMemoryRepository()
+	srv, err := NewServer(cfg, repo, nil)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+	t.Run("encrypted, compressed and signed batch", func(t *testing.T) {
+		s := sender.New(ts.URL, "secret")
+		s.PublicKey = &key.PublicKey
+		err := s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 7.5})
+		require.NoError(t, err)
+
+		value, err := repo.GetGauge(context.Background(), "Alloc")
+		assert.NoError(t, err)
+		assert.Equal(t, 7.5, value)
+	})
+
+	t.Run("plaintext body is rejected", func(t *testing.T) {
+		s := sender.New(ts.URL, "secret")
+		err := s.SendMetricsBatch(context.Background(), map[string]interface{}{"Alloc": 1.0})
+		assert.Error(t, err)
+	})
+
+	t.Run("missing key file", func(t *testing.T) {
+		_, err := NewServer(&config.Config{CryptoKey: filepath.Join(t.TempDir(), "missing.pem")}, repo, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestTrustedSubnet(t *testing.T) {
+	cfg := &config.Config{ServerAddr: "localhost:8080", TrustedSubnet: "10.0.0.0/8"}
+	srv, err := NewServer(cfg, repository.NewMemoryRepository(), nil)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+	do := func(method, path, realIP string) int {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		require.NoError(t, err)
+		if realIP != "" {
+			req.Header.Set("X-Real-IP", realIP)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/update/gauge/test/1", "192.168.0.1"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/update/gauge/test/1", ""))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/updates/", "192.168.0.1"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/update/", "192.168.0.1"))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/update/gauge/test/1", "10.1.2.3"))
+
+	// Чтение доступно из любой сети
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/value/gauge/test", ""))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/", "192.168.0.1"))
+
+	_, err = NewServer(&config.Config{TrustedSubnet: "invalid"}, repository.NewMemoryRepository(), nil)
+	assert.Error(t, err)
 }